@@ -14,12 +14,14 @@ require (
 	github.com/pion/rtcp v1.2.6
 	github.com/pion/rtp v1.6.2
 	github.com/pion/sctp v1.7.11
+	github.com/pion/sdp/v3 v3.0.4
 	github.com/pion/transport v0.12.3
 	github.com/pion/webrtc/v3 v3.0.18
 	github.com/prometheus/client_golang v1.6.0
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.7.0
 	go.uber.org/goleak v1.0.0
+	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
 	gopkg.in/yaml.v2 v2.3.0
 	nhooyr.io/websocket v1.8.4
 )
@@ -37,7 +39,6 @@ require (
 	github.com/pion/dtls/v2 v2.0.8 // indirect
 	github.com/pion/ice/v2 v2.0.16 // indirect
 	github.com/pion/mdns v0.0.5 // indirect
-	github.com/pion/sdp/v3 v3.0.4 // indirect
 	github.com/pion/srtp/v2 v2.0.2 // indirect
 	github.com/pion/stun v0.3.5 // indirect
 	github.com/pion/turn/v2 v2.0.5 // indirect
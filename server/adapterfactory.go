@@ -15,6 +15,10 @@ type AdapterFactory struct {
 	subClient *redis.Client
 
 	NewAdapter func(room identifiers.RoomID) Adapter
+
+	// SnapshotStore is nil when no persistent storage for snapshots is
+	// configured.
+	SnapshotStore SnapshotStore
 }
 
 func NewAdapterFactory(log logger.Logger, c StoreConfig) *AdapterFactory {
@@ -42,12 +46,18 @@ func NewAdapterFactory(log logger.Logger, c StoreConfig) *AdapterFactory {
 		f.NewAdapter = func(room identifiers.RoomID) Adapter {
 			return NewRedisAdapter(log, f.pubClient, f.subClient, prefix, room)
 		}
+
+		f.SnapshotStore = NewRedisSnapshotStore(f.pubClient, prefix, c.Snapshot.TTL)
 	default:
 		log.Info("Using MemoryAdapter", nil)
 
 		f.NewAdapter = func(room identifiers.RoomID) Adapter {
 			return NewMemoryAdapter(room)
 		}
+
+		if c.Snapshot.File != "" {
+			f.SnapshotStore = NewFileSnapshotStore(c.Snapshot.File)
+		}
 	}
 
 	return &f
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/logger"
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
+)
+
+type APIHandlerParams struct {
+	Log         logger.Logger
+	AccessToken string
+	Migrator    *Migrator
+	Thumbnailer *sfu.Thumbnailer
+
+	SimulcastLadders *sfu.SimulcastLadders
+
+	Replicator *Replicator
+}
+
+// APIHandler serves the admin API. All routes require the admin access
+// token.
+type APIHandler struct {
+	params  *APIHandlerParams
+	handler *chi.Mux
+}
+
+var _ http.Handler = &APIHandler{}
+
+func NewAPIHandler(params APIHandlerParams) *APIHandler {
+	params.Log = params.Log.WithNamespaceAppended("api")
+
+	handler := chi.NewRouter()
+
+	a := &APIHandler{
+		params:  &params,
+		handler: handler,
+	}
+
+	handler.Use(a.authenticate)
+
+	if params.Migrator != nil {
+		handler.Post("/rooms/{roomID}/migrate", a.routeMigrate)
+		handler.Post("/rooms/{roomID}/prepare", a.routePrepare)
+	}
+
+	if params.Thumbnailer != nil {
+		handler.Get("/rooms/{roomID}/thumbnails", a.routeThumbnails)
+		handler.Get("/rooms/{roomID}/thumbnails/{streamID}/{trackID}", a.routeThumbnail)
+	}
+
+	if params.SimulcastLadders != nil {
+		handler.Get("/rooms/{roomID}/simulcast", a.routeGetSimulcast)
+		handler.Put("/rooms/{roomID}/simulcast", a.routePutSimulcast)
+		handler.Delete("/rooms/{roomID}/simulcast", a.routeDeleteSimulcast)
+	}
+
+	if params.Replicator != nil {
+		handler.Get("/replication", a.routeReplicationStatus)
+		handler.Put("/replication/snapshots", a.routeReplicationSnapshots)
+		handler.Post("/replication/promote", a.routeReplicationPromote)
+	}
+
+	return a
+}
+
+func (a *APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+// accessTokenFromRequest reads the access token from the Authorization
+// header, or the access_token form value when the header is not set.
+func accessTokenFromRequest(r *http.Request) string {
+	accessToken := r.Header.Get("Authorization")
+	if strings.HasPrefix(accessToken, "Bearer ") {
+		return accessToken[len("Bearer "):]
+	}
+
+	return r.FormValue("access_token")
+}
+
+func (a *APIHandler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken := accessTokenFromRequest(r)
+
+		if accessToken == "" || accessToken != a.params.AccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *APIHandler) writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		a.params.Log.Error("Write JSON response", errors.Trace(err), nil)
+	}
+}
+
+func (a *APIHandler) writeError(w http.ResponseWriter, statusCode int, err error) {
+	a.params.Log.Error("API error", errors.Trace(err), nil)
+
+	a.writeJSON(w, statusCode, map[string]string{
+		"error": errors.Cause(err).Error(),
+	})
+}
+
+type migrateRequest struct {
+	// TargetURL is the base URL of the target node.
+	TargetURL string `json:"targetUrl"`
+}
+
+func (a *APIHandler) routeMigrate(w http.ResponseWriter, r *http.Request) {
+	room := identifiers.RoomID(chi.URLParam(r, "roomID"))
+
+	var req migrateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.writeError(w, http.StatusBadRequest, errors.Annotate(err, "decode migrate request"))
+
+		return
+	}
+
+	if req.TargetURL == "" {
+		a.writeError(w, http.StatusBadRequest, errors.Errorf("targetUrl is required"))
+
+		return
+	}
+
+	result, err := a.params.Migrator.Migrate(r.Context(), room, req.TargetURL)
+
+	switch {
+	case multierr.Is(err, ErrRoomNotFound):
+		a.writeError(w, http.StatusNotFound, errors.Trace(err))
+	case multierr.Is(err, ErrRoomMigrating):
+		a.writeError(w, http.StatusConflict, errors.Trace(err))
+	case err != nil:
+		a.writeError(w, http.StatusBadGateway, errors.Trace(err))
+	default:
+		// The room is drained in the background.
+		a.writeJSON(w, http.StatusAccepted, result)
+	}
+}
+
+func (a *APIHandler) routePrepare(w http.ResponseWriter, r *http.Request) {
+	room := identifiers.RoomID(chi.URLParam(r, "roomID"))
+
+	var snapshot RoomSnapshot
+
+	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+		a.writeError(w, http.StatusBadRequest, errors.Annotate(err, "decode snapshot"))
+
+		return
+	}
+
+	if snapshot.RoomID != room {
+		a.writeError(w, http.StatusBadRequest, errors.Errorf("room mismatch: %s", snapshot.RoomID))
+
+		return
+	}
+
+	a.params.Migrator.Prepare(snapshot)
+
+	a.writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (a *APIHandler) routeThumbnails(w http.ResponseWriter, r *http.Request) {
+	room := identifiers.RoomID(chi.URLParam(r, "roomID"))
+
+	a.writeJSON(w, http.StatusOK, a.params.Thumbnailer.Thumbnails(room))
+}
+
+func (a *APIHandler) routeThumbnail(w http.ResponseWriter, r *http.Request) {
+	room := identifiers.RoomID(chi.URLParam(r, "roomID"))
+
+	streamID, err := url.PathUnescape(chi.URLParam(r, "streamID"))
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, errors.Annotate(err, "unescape streamID"))
+
+		return
+	}
+
+	id, err := url.PathUnescape(chi.URLParam(r, "trackID"))
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, errors.Annotate(err, "unescape trackID"))
+
+		return
+	}
+
+	trackID := identifiers.TrackID{
+		ID:       id,
+		StreamID: streamID,
+	}
+
+	thumbnail, ok := a.params.Thumbnailer.Thumbnail(room, trackID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Last-Modified", thumbnail.Timestamp.UTC().Format(http.TimeFormat))
+
+	if _, err := w.Write(thumbnail.JPEG); err != nil {
+		a.params.Log.Error("Write thumbnail", errors.Trace(err), nil)
+	}
+}
+
+func (a *APIHandler) routeGetSimulcast(w http.ResponseWriter, r *http.Request) {
+	room := identifiers.RoomID(chi.URLParam(r, "roomID"))
+
+	a.writeJSON(w, http.StatusOK, a.params.SimulcastLadders.Ladder(room))
+}
+
+// routePutSimulcast sets the simulcast ladder for the publishers that join
+// the room after the change.
+func (a *APIHandler) routePutSimulcast(w http.ResponseWriter, r *http.Request) {
+	room := identifiers.RoomID(chi.URLParam(r, "roomID"))
+
+	var ladder sfu.SimulcastLadder
+
+	if err := json.NewDecoder(r.Body).Decode(&ladder); err != nil {
+		a.writeError(w, http.StatusBadRequest, errors.Annotate(err, "decode simulcast ladder"))
+
+		return
+	}
+
+	if len(ladder) == 0 {
+		a.writeError(w, http.StatusBadRequest, errors.Errorf("simulcast ladder is required"))
+
+		return
+	}
+
+	if err := a.params.SimulcastLadders.SetLadder(room, ladder); err != nil {
+		a.writeError(w, http.StatusBadRequest, errors.Trace(err))
+
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, ladder)
+}
+
+// routeDeleteSimulcast restores the default simulcast ladder.
+func (a *APIHandler) routeDeleteSimulcast(w http.ResponseWriter, r *http.Request) {
+	room := identifiers.RoomID(chi.URLParam(r, "roomID"))
+
+	if err := a.params.SimulcastLadders.SetLadder(room, nil); err != nil {
+		a.writeError(w, http.StatusInternalServerError, errors.Trace(err))
+
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, a.params.SimulcastLadders.Ladder(room))
+}
+
+func (a *APIHandler) routeReplicationStatus(w http.ResponseWriter, r *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.params.Replicator.Status())
+}
+
+func (a *APIHandler) routeReplicationSnapshots(w http.ResponseWriter, r *http.Request) {
+	var snapshots []RoomSnapshot
+
+	if err := json.NewDecoder(r.Body).Decode(&snapshots); err != nil {
+		a.writeError(w, http.StatusBadRequest, errors.Annotate(err, "decode snapshots"))
+
+		return
+	}
+
+	if err := a.params.Replicator.Receive(snapshots); err != nil {
+		statusCode := http.StatusBadRequest
+
+		if multierr.Is(err, ErrPromoted) {
+			// Tells the active node to step down.
+			statusCode = http.StatusConflict
+		}
+
+		a.writeError(w, statusCode, errors.Trace(err))
+
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, struct{}{})
+}
+
+func (a *APIHandler) routeReplicationPromote(w http.ResponseWriter, r *http.Request) {
+	if _, err := a.params.Replicator.Promote(); err != nil {
+		a.writeError(w, http.StatusConflict, errors.Trace(err))
+
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, a.params.Replicator.Status())
+}
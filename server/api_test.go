@@ -0,0 +1,99 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/peer-calls/peer-calls/v4/server"
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIServer(t *testing.T, params server.APIHandlerParams) *httptest.Server {
+	t.Helper()
+
+	params.Log = test.NewLogger()
+	params.AccessToken = adminAccessToken
+
+	s := httptest.NewServer(server.NewAPIHandler(params))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func doAPIRequest(t *testing.T, method string, url string, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminAccessToken)
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	return res.StatusCode, string(b)
+}
+
+func TestAPIHandler_thumbnails(t *testing.T) {
+	s := newAPIServer(t, server.APIHandlerParams{
+		Thumbnailer: sfu.NewThumbnailer(sfu.ThumbnailerParams{
+			Log: test.NewLogger(),
+		}),
+	})
+
+	statusCode, body := doAPIRequest(t, http.MethodGet, s.URL+"/rooms/room1/thumbnails", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, "[]", body)
+
+	statusCode, _ = doAPIRequest(t, http.MethodGet, s.URL+"/rooms/room1/thumbnails/stream1/track1", "")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+}
+
+func TestAPIHandler_simulcast(t *testing.T) {
+	ladders := sfu.NewSimulcastLadders(sfu.DefaultSimulcastLadder(), nil)
+
+	s := newAPIServer(t, server.APIHandlerParams{
+		SimulcastLadders: ladders,
+	})
+
+	url := s.URL + "/rooms/room1/simulcast"
+
+	statusCode, body := doAPIRequest(t, http.MethodGet, url, "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, `[
+		{"rid":"q","scaleResolutionDownBy":4,"maxBitrate":150000},
+		{"rid":"h","scaleResolutionDownBy":2,"maxBitrate":500000},
+		{"rid":"f","scaleResolutionDownBy":1,"maxBitrate":1500000}
+	]`, body)
+
+	ladder := `[{"rid":"l","scaleResolutionDownBy":2,"maxBitrate":300000},{"rid":"h","scaleResolutionDownBy":1,"maxBitrate":900000}]`
+
+	statusCode, _ = doAPIRequest(t, http.MethodPut, url, ladder)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, sfu.SimulcastLadder{
+		{RID: "l", ScaleResolutionDownBy: 2, MaxBitrate: 300000},
+		{RID: "h", ScaleResolutionDownBy: 1, MaxBitrate: 900000},
+	}, ladders.Ladder("room1"))
+
+	statusCode, _ = doAPIRequest(t, http.MethodPut, url, `[{"rid":""}]`)
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	for _, body := range []string{"null", "[]"} {
+		statusCode, _ = doAPIRequest(t, http.MethodPut, url, body)
+		assert.Equal(t, http.StatusBadRequest, statusCode, "body: %s", body)
+	}
+
+	statusCode, _ = doAPIRequest(t, http.MethodDelete, url, "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, sfu.DefaultSimulcastLadder(), ladders.Ladder("room1"))
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+const audioActivityChannelSize = 16
+
+// AudioActivityEvent is emitted when the sustained activity of a published
+// audio track changes.
+type AudioActivityEvent struct {
+	TrackID  identifiers.TrackID
+	Activity sfu.AudioActivity
+}
+
+// audioLevelExtensionID returns the negotiated ID of the audio level header
+// extension.
+func audioLevelExtensionID(receiver *webrtc.RTPReceiver) (uint8, bool) {
+	for _, ext := range receiver.GetParameters().HeaderExtensions {
+		if ext.URI == sdp.AudioLevelURI {
+			return uint8(ext.ID), true
+		}
+	}
+
+	return 0, false
+}
+
+// audioLevelTrack feeds the audio levels of all read packets to the
+// AudioLevelDetector. It does not modify the packets.
+type audioLevelTrack struct {
+	RemoteTrack
+
+	extensionID uint8
+	detector    *sfu.AudioLevelDetector
+	onActivity  func(AudioActivityEvent)
+}
+
+func newAudioLevelTrack(
+	track RemoteTrack,
+	extensionID uint8,
+	onActivity func(AudioActivityEvent),
+) *audioLevelTrack {
+	return &audioLevelTrack{
+		RemoteTrack: track,
+		extensionID: extensionID,
+		detector:    sfu.NewAudioLevelDetector(sfu.AudioLevelDetectorParams{}),
+		onActivity:  onActivity,
+	}
+}
+
+func (t *audioLevelTrack) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
+	packet, attributes, err := t.RemoteTrack.ReadRTP()
+	if err != nil {
+		return packet, attributes, err
+	}
+
+	payload := packet.GetExtension(t.extensionID)
+	if payload == nil {
+		return packet, attributes, nil
+	}
+
+	var ext rtp.AudioLevelExtension
+
+	if err := ext.Unmarshal(payload); err != nil {
+		return packet, attributes, nil
+	}
+
+	if activity, changed := t.detector.Feed(ext.Level, time.Now()); changed {
+		t.onActivity(AudioActivityEvent{
+			TrackID:  t.Track().TrackID(),
+			Activity: activity,
+		})
+	}
+
+	return packet, attributes, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/juju/errors"
 	"github.com/peer-calls/peer-calls/v4/server"
 	"github.com/peer-calls/peer-calls/v4/server/logger"
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
 )
 
 type Props struct {
@@ -13,6 +14,10 @@ type Props struct {
 	Version string
 	Args    []string
 	Embed   server.Embed
+
+	// KeyFrameDecoders are used to decode video thumbnails. They are indexed
+	// by mime type. Defaults to sfu.DefaultKeyFrameDecoders.
+	KeyFrameDecoders map[string]sfu.KeyFrameDecoder
 }
 
 func Exec(ctx context.Context, props Props) error {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path"
 	"strconv"
@@ -21,11 +22,13 @@ type serverHandler struct {
 		config string
 	}
 
-	log    logger.Logger
-	config server.Config
-	props  Props
-	server *server.Server
-	mux    *server.Mux
+	log         logger.Logger
+	config      server.Config
+	props       Props
+	server      *server.Server
+	mux         *server.Mux
+	snapshotter *server.Snapshotter
+	replicator  *server.Replicator
 }
 
 func (h *serverHandler) RegisterFlags(c *command.Command, flags *pflag.FlagSet) {
@@ -37,6 +40,8 @@ func (h *serverHandler) Handle(ctx context.Context, args []string) error {
 		return errors.Trace(err)
 	}
 
+	defer h.mux.Close()
+
 	listener, err := net.Listen("tcp", net.JoinHostPort(
 		h.config.BindHost,
 		strconv.Itoa(h.config.BindPort),
@@ -57,6 +62,14 @@ func (h *serverHandler) Handle(ctx context.Context, args []string) error {
 		"local_addr": addr,
 	})
 
+	if h.snapshotter != nil {
+		go h.snapshotter.Run(ctx)
+	}
+
+	if h.replicator != nil {
+		go h.replicator.Run(ctx)
+	}
+
 	err = h.server.Start(ctx, listener)
 
 	return errors.Trace(err)
@@ -101,18 +114,118 @@ func (h *serverHandler) configure() (err error) {
 		}
 	}
 
-	tracks := sfu.NewTracksManager(log, c.Network.SFU.JitterBuffer)
+	var thumbnailer *sfu.Thumbnailer
+
+	if thumbnails := c.Network.SFU.Thumbnails; thumbnails.Interval > 0 {
+		thumbnailer = sfu.NewThumbnailer(sfu.ThumbnailerParams{
+			Log:      log,
+			Interval: thumbnails.Interval,
+			Quality:  thumbnails.Quality,
+			Decoders: h.props.KeyFrameDecoders,
+		})
+	}
+
+	simulcast := c.Network.SFU.Simulcast
+
+	if err := simulcast.Layers.Validate(); err != nil {
+		return errors.Annotate(err, "validate simulcast layers")
+	}
+
+	for room, ladder := range simulcast.Rooms {
+		if err := ladder.Validate(); err != nil {
+			return errors.Annotatef(err, "validate simulcast layers for room: %s", room)
+		}
+	}
+
+	simulcastLadders := sfu.NewSimulcastLadders(simulcast.Layers, simulcast.Rooms)
+
+	tracks := sfu.NewTracksManager(sfu.TracksManagerParams{
+		Log:                 log,
+		JitterBufferEnabled: c.Network.SFU.JitterBuffer,
+		Thumbnailer:         thumbnailer,
+		SimulcastLadders:    simulcastLadders,
+	})
+
+	adapterFactory := server.NewAdapterFactory(log, c.Store)
 
 	roomManagerFactory := server.NewRoomManagerFactory(server.RoomManagerFactoryParams{
-		AdapterFactory: server.NewAdapterFactory(log, c.Store),
+		AdapterFactory: adapterFactory,
 		Log:            log,
 		TracksManager:  tracks,
 	})
 	rooms, _ := roomManagerFactory.NewRoomManager(c.Network)
 
+	snapshotRooms, ok := rooms.(server.RoomSnapshotter)
+	if ok && c.Store.Snapshot.Interval > 0 && adapterFactory.SnapshotStore != nil {
+		h.snapshotter = server.NewSnapshotter(server.SnapshotterParams{
+			Log:      log,
+			Store:    adapterFactory.SnapshotStore,
+			Rooms:    snapshotRooms,
+			Interval: c.Store.Snapshot.Interval,
+			TTL:      c.Store.Snapshot.TTL,
+		})
+
+		if err := h.snapshotter.Restore(); err != nil {
+			log.Error("Restore snapshots", errors.Trace(err), nil)
+		}
+	}
+
+	if replication := c.Replication; replication.Role != "" {
+		switch {
+		case !ok:
+			return errors.Errorf("replication is not supported for network type: %s", c.Network.Type)
+		case c.Admin.AccessToken == "":
+			return errors.Errorf("replication requires the admin access token to be set")
+		case replication.Role == server.ReplicationRoleActive && replication.PeerURL == "":
+			return errors.Errorf("replication peer url is required for role: %s", replication.Role)
+		case replication.Role != server.ReplicationRoleActive && replication.Role != server.ReplicationRoleStandby:
+			return errors.Errorf("invalid replication role: %s", replication.Role)
+		}
+
+		h.replicator = server.NewReplicator(server.ReplicatorParams{
+			Log:             log,
+			Rooms:           snapshotRooms,
+			Role:            replication.Role,
+			PeerURL:         replication.PeerURL,
+			AccessToken:     c.Admin.AccessToken,
+			HTTPClient:      nil,
+			Interval:        replication.Interval,
+			AutoFailover:    replication.AutoFailover,
+			FailoverTimeout: replication.FailoverTimeout,
+			TTL:             c.Store.Snapshot.TTL,
+		})
+	}
+
 	encodedInsertableStreams := c.Frontend.EncodedInsertableStreams
 
-	h.mux = server.NewMux(log, c.BaseURL, h.props.Version, c.Network, c.ICEServers, encodedInsertableStreams, rooms, tracks, c.Prometheus, h.props.Embed)
+	var api http.Handler
+
+	if c.Admin.AccessToken != "" {
+		var migrator *server.Migrator
+
+		if migratableRooms, ok := rooms.(server.MigratableRoomManager); ok {
+			migrator = server.NewMigrator(server.MigratorParams{
+				Log:          log,
+				Rooms:        migratableRooms,
+				AccessToken:  c.Admin.AccessToken,
+				HTTPClient:   nil,
+				DrainTimeout: c.Admin.DrainTimeout,
+				ResumeTTL:    c.Store.Snapshot.TTL,
+			})
+		}
+
+		api = server.NewAPIHandler(server.APIHandlerParams{
+			Log:         log,
+			AccessToken: c.Admin.AccessToken,
+			Migrator:    migrator,
+			Thumbnailer: thumbnailer,
+			Replicator:  h.replicator,
+
+			SimulcastLadders: simulcastLadders,
+		})
+	}
+
+	h.mux = server.NewMux(log, c.BaseURL, h.props.Version, c.Network, c.ICEServers, encodedInsertableStreams, c.Admin.MigrationOrigins, rooms, tracks, simulcastLadders, h.replicator, c.Prometheus, h.props.Embed, api)
 
 	return nil
 }
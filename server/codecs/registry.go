@@ -6,6 +6,7 @@ import (
 	"github.com/juju/errors"
 	"github.com/peer-calls/peer-calls/v4/server/transport"
 	"github.com/pion/interceptor"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 )
 
@@ -26,6 +27,18 @@ type HeaderExtension struct {
 	AllowedDirections []webrtc.RTPTransceiverDirection
 }
 
+// AudioLevelExtensionID is the ID of the RFC 6464 audio level header
+// extension used between server transports. The WebRTC transports use the
+// negotiated ID instead.
+const AudioLevelExtensionID = 1
+
+// MidExtensionID and RTPStreamIDExtensionID are the IDs of the video header
+// extensions used for receiving simulcast.
+const (
+	MidExtensionID         = 2
+	RTPStreamIDExtensionID = 3
+)
+
 const (
 	clockRateOpus   = 48000
 	PayloadTypeOpus = 111
@@ -70,7 +83,18 @@ func NewRegistryDefault() *Registry {
 					PayloadType:        PayloadTypeOpus,
 				},
 			},
-			HeaderExtensions: nil,
+			HeaderExtensions: []HeaderExtension{
+				{
+					Parameter: webrtc.RTPHeaderExtensionParameter{
+						URI: sdp.AudioLevelURI,
+						ID:  AudioLevelExtensionID,
+					},
+					// The server only needs to receive the audio levels.
+					AllowedDirections: []webrtc.RTPTransceiverDirection{
+						webrtc.RTPTransceiverDirectionRecvonly,
+					},
+				},
+			},
 		},
 		Video: Props{
 			CodecParameters: []webrtc.RTPCodecParameters{
@@ -153,7 +177,28 @@ func NewRegistryDefault() *Registry {
 					PayloadType:        97,
 				},
 			},
-			HeaderExtensions: nil,
+			HeaderExtensions: []HeaderExtension{
+				// The MID and RID extensions are needed to receive the layers of
+				// simulcast publishers.
+				{
+					Parameter: webrtc.RTPHeaderExtensionParameter{
+						URI: sdp.SDESMidURI,
+						ID:  MidExtensionID,
+					},
+					AllowedDirections: []webrtc.RTPTransceiverDirection{
+						webrtc.RTPTransceiverDirectionRecvonly,
+					},
+				},
+				{
+					Parameter: webrtc.RTPHeaderExtensionParameter{
+						URI: sdp.SDESRTPStreamIDURI,
+						ID:  RTPStreamIDExtensionID,
+					},
+					AllowedDirections: []webrtc.RTPTransceiverDirection{
+						webrtc.RTPTransceiverDirectionRecvonly,
+					},
+				},
+			},
 		},
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	"gopkg.in/yaml.v2"
@@ -75,6 +76,9 @@ func ReadConfigFromEnv(prefix string, c *Config) {
 	setEnvString(&c.Store.Redis.Host, prefix+"STORE_REDIS_HOST")
 	setEnvInt(&c.Store.Redis.Port, prefix+"STORE_REDIS_PORT")
 	setEnvString(&c.Store.Redis.Prefix, prefix+"STORE_REDIS_PREFIX")
+	setEnvDuration(&c.Store.Snapshot.Interval, prefix+"STORE_SNAPSHOT_INTERVAL")
+	setEnvDuration(&c.Store.Snapshot.TTL, prefix+"STORE_SNAPSHOT_TTL")
+	setEnvString(&c.Store.Snapshot.File, prefix+"STORE_SNAPSHOT_FILE")
 
 	setEnvNetworkType(&c.Network.Type, prefix+"NETWORK_TYPE")
 	setEnvString(&c.Network.SFU.TCPBindAddr, prefix+"NETWORK_SFU_TCP_BIND_ADDR")
@@ -84,8 +88,12 @@ func ReadConfigFromEnv(prefix string, c *Config) {
 	setEnvBool(&c.Network.SFU.JitterBuffer, prefix+"NETWORK_SFU_JITTER_BUFFER")
 	setEnvStringArray(&c.Network.SFU.Transport.Nodes, prefix+"NETWORK_SFU_TRANSPORT_NODES")
 	setEnvString(&c.Network.SFU.Transport.ListenAddr, prefix+"NETWORK_SFU_TRANSPORT_LISTEN_ADDR")
+	setEnvInt(&c.Network.SFU.Transport.ReceiveMTU, prefix+"NETWORK_SFU_TRANSPORT_RECEIVE_MTU")
 	setEnvUint16(&c.Network.SFU.UDP.PortMin, prefix+"NETWORK_SFU_UDP_PORT_MIN")
 	setEnvUint16(&c.Network.SFU.UDP.PortMax, prefix+"NETWORK_SFU_UDP_PORT_MAX")
+	setEnvDuration(&c.Network.SFU.Thumbnails.Interval, prefix+"NETWORK_SFU_THUMBNAILS_INTERVAL")
+	setEnvInt(&c.Network.SFU.Thumbnails.Quality, prefix+"NETWORK_SFU_THUMBNAILS_QUALITY")
+	setEnvString(&c.Network.SFU.PeerStateWebhookURL, prefix+"NETWORK_SFU_PEER_STATE_WEBHOOK_URL")
 
 	if value, ok := os.LookupEnv(prefix + "ICE_SERVER_URLS"); ok {
 		// Do not use the default servers, even if value is empty.
@@ -105,6 +113,16 @@ func ReadConfigFromEnv(prefix string, c *Config) {
 
 	setEnvString(&c.Prometheus.AccessToken, prefix+"PROMETHEUS_ACCESS_TOKEN")
 
+	setEnvString(&c.Admin.AccessToken, prefix+"ADMIN_ACCESS_TOKEN")
+	setEnvDuration(&c.Admin.DrainTimeout, prefix+"ADMIN_DRAIN_TIMEOUT")
+	setEnvSlice(&c.Admin.MigrationOrigins, prefix+"ADMIN_MIGRATION_ORIGINS")
+
+	setEnvReplicationRole(&c.Replication.Role, prefix+"REPLICATION_ROLE")
+	setEnvString(&c.Replication.PeerURL, prefix+"REPLICATION_PEER_URL")
+	setEnvDuration(&c.Replication.Interval, prefix+"REPLICATION_INTERVAL")
+	setEnvBool(&c.Replication.AutoFailover, prefix+"REPLICATION_AUTO_FAILOVER")
+	setEnvDuration(&c.Replication.FailoverTimeout, prefix+"REPLICATION_FAILOVER_TIMEOUT")
+
 	setEnvBool(&c.Frontend.EncodedInsertableStreams, prefix+"FRONTEND_ENCODED_INSERTABLE_STREAMS")
 }
 
@@ -142,6 +160,13 @@ func setEnvUint16(dest *uint16, name string) {
 	}
 }
 
+func setEnvDuration(dest *time.Duration, name string) {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err == nil {
+		*dest = value
+	}
+}
+
 func setEnvBool(dest *bool, name string) {
 	val := os.Getenv(name)
 
@@ -186,6 +211,16 @@ func setEnvStoreType(storeType *StoreType, name string) {
 	}
 }
 
+func setEnvReplicationRole(role *ReplicationRole, name string) {
+	value := os.Getenv(name)
+	switch ReplicationRole(value) {
+	case ReplicationRoleActive:
+		*role = ReplicationRoleActive
+	case ReplicationRoleStandby:
+		*role = ReplicationRoleStandby
+	}
+}
+
 func setEnvStringArray(interfaces *[]string, name string) {
 	value := os.Getenv(name)
 	if value != "" {
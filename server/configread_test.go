@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/peer-calls/peer-calls/v4/server"
 	"github.com/peer-calls/peer-calls/v4/server/test"
@@ -70,6 +71,9 @@ func TestReadFromEnv(t *testing.T) {
 	os.Setenv(prefix+"STORE_REDIS_HOST", "localhost")
 	os.Setenv(prefix+"STORE_REDIS_PORT", "6379")
 	os.Setenv(prefix+"STORE_REDIS_PREFIX", "peercalls")
+	os.Setenv(prefix+"STORE_SNAPSHOT_INTERVAL", "10s")
+	os.Setenv(prefix+"STORE_SNAPSHOT_TTL", "2m")
+	os.Setenv(prefix+"STORE_SNAPSHOT_FILE", "/tmp/snapshots.json")
 	os.Setenv(prefix+"ICE_SERVER_URLS", "stun:stun.l.google.com:19302,stuns:stun.l.google.com:19302")
 	os.Setenv(prefix+"ICE_SERVER_AUTH_TYPE", "secret")
 	os.Setenv(prefix+"ICE_SERVER_USERNAME", "test_user")
@@ -81,9 +85,21 @@ func TestReadFromEnv(t *testing.T) {
 	os.Setenv(prefix+"NETWORK_SFU_JITTER_BUFFER", "true")
 	os.Setenv(prefix+"NETWORK_SFU_UDP_PORT_MIN", "9000")
 	os.Setenv(prefix+"NETWORK_SFU_UDP_PORT_MAX", "9010")
+	os.Setenv(prefix+"NETWORK_SFU_THUMBNAILS_INTERVAL", "5s")
+	os.Setenv(prefix+"NETWORK_SFU_THUMBNAILS_QUALITY", "80")
+	os.Setenv(prefix+"NETWORK_SFU_PEER_STATE_WEBHOOK_URL", "http://localhost:8080/hook")
 	os.Setenv(prefix+"PROMETHEUS_ACCESS_TOKEN", "at1234")
+	os.Setenv(prefix+"ADMIN_ACCESS_TOKEN", "admin1234")
+	os.Setenv(prefix+"ADMIN_DRAIN_TIMEOUT", "45s")
+	os.Setenv(prefix+"ADMIN_MIGRATION_ORIGINS", "node1.example.com,*.example.net")
+	os.Setenv(prefix+"REPLICATION_ROLE", "active")
+	os.Setenv(prefix+"REPLICATION_PEER_URL", "http://standby:3000")
+	os.Setenv(prefix+"REPLICATION_INTERVAL", "2s")
+	os.Setenv(prefix+"REPLICATION_AUTO_FAILOVER", "true")
+	os.Setenv(prefix+"REPLICATION_FAILOVER_TIMEOUT", "8s")
 	os.Setenv(prefix+"NETWORK_SFU_TRANSPORT_NODES", "127.0.0.1:3005,127.0.0.1:3006")
 	os.Setenv(prefix+"NETWORK_SFU_TRANSPORT_LISTEN_ADDR", "127.0.0.1:3004")
+	os.Setenv(prefix+"NETWORK_SFU_TRANSPORT_RECEIVE_MTU", "9000")
 	var c server.Config
 	server.ReadConfigFromEnv(prefix, &c)
 	assert.Equal(t, "/test", c.BaseURL)
@@ -93,6 +109,9 @@ func TestReadFromEnv(t *testing.T) {
 	assert.Equal(t, "localhost", c.Store.Redis.Host)
 	assert.Equal(t, 6379, c.Store.Redis.Port)
 	assert.Equal(t, "peercalls", c.Store.Redis.Prefix)
+	assert.Equal(t, 10*time.Second, c.Store.Snapshot.Interval)
+	assert.Equal(t, 2*time.Minute, c.Store.Snapshot.TTL)
+	assert.Equal(t, "/tmp/snapshots.json", c.Store.Snapshot.File)
 	assert.Equal(t, 1, len(c.ICEServers))
 	assert.Equal(t, []server.ICEServer{
 		{
@@ -114,9 +133,23 @@ func TestReadFromEnv(t *testing.T) {
 	assert.Equal(t, true, c.Network.SFU.JitterBuffer)
 	assert.Equal(t, uint16(9000), c.Network.SFU.UDP.PortMin)
 	assert.Equal(t, uint16(9010), c.Network.SFU.UDP.PortMax)
+	assert.Equal(t, 5*time.Second, c.Network.SFU.Thumbnails.Interval)
+	assert.Equal(t, 80, c.Network.SFU.Thumbnails.Quality)
+	assert.Equal(t, "http://localhost:8080/hook", c.Network.SFU.PeerStateWebhookURL)
 	assert.Equal(t, "at1234", c.Prometheus.AccessToken)
+	assert.Equal(t, "admin1234", c.Admin.AccessToken)
+	assert.Equal(t, 45*time.Second, c.Admin.DrainTimeout)
+	assert.Equal(t, []string{"node1.example.com", "*.example.net"}, c.Admin.MigrationOrigins)
+	assert.Equal(t, server.ReplicationConfig{
+		Role:            server.ReplicationRoleActive,
+		PeerURL:         "http://standby:3000",
+		Interval:        2 * time.Second,
+		AutoFailover:    true,
+		FailoverTimeout: 8 * time.Second,
+	}, c.Replication)
 	assert.Equal(t, "127.0.0.1:3004", c.Network.SFU.Transport.ListenAddr)
 	assert.Equal(t, []string{"127.0.0.1:3005", "127.0.0.1:3006"}, c.Network.SFU.Transport.Nodes)
+	assert.Equal(t, 9000, c.Network.SFU.Transport.ReceiveMTU)
 
 	t.Run("disable default ICE servers", func(t *testing.T) {
 		prefix := "PEERCALLSTEST_"
@@ -1,5 +1,12 @@
 package server
 
+import (
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
+)
+
 type AuthType string
 
 const (
@@ -35,8 +42,20 @@ type RedisConfig struct {
 }
 
 type StoreConfig struct {
-	Type  StoreType   `yaml:"type"`
-	Redis RedisConfig `yaml:"redis"`
+	Type     StoreType      `yaml:"type"`
+	Redis    RedisConfig    `yaml:"redis"`
+	Snapshot SnapshotConfig `yaml:"snapshot"`
+}
+
+type SnapshotConfig struct {
+	// Interval between two room state snapshots. Snapshots are disabled when
+	// the interval is zero.
+	Interval time.Duration `yaml:"interval"`
+	// TTL defines for how long a snapshot can be restored after it was taken.
+	TTL time.Duration `yaml:"ttl"`
+	// File is used to store snapshots when the memory store is used. It is
+	// ignored for the redis store.
+	File string `yaml:"file"`
 }
 
 type NetworkType string
@@ -63,17 +82,78 @@ type NetworkConfigSFU struct {
 		PortMin uint16 `yaml:"port_min"`
 		PortMax uint16 `yaml:"port_max"`
 	} `yaml:"udp"`
+	Thumbnails ThumbnailsConfig `yaml:"thumbnails"`
+	Simulcast  SimulcastConfig  `yaml:"simulcast"`
+	// PeerStateWebhookURL receives a POST request with a JSON body for each
+	// peer connection state transition. Disabled when empty.
+	PeerStateWebhookURL string `yaml:"peer_state_webhook_url"`
+}
+
+type SimulcastConfig struct {
+	// Layers is the default simulcast ladder advertised to publishers.
+	// Simulcast is disabled when empty.
+	Layers sfu.SimulcastLadder `yaml:"layers"`
+	// Rooms contains simulcast ladders for specific rooms.
+	Rooms map[identifiers.RoomID]sfu.SimulcastLadder `yaml:"rooms"`
+}
+
+type ThumbnailsConfig struct {
+	// Interval between two thumbnails of the same video track. Thumbnails are
+	// disabled when zero.
+	Interval time.Duration `yaml:"interval"`
+	// Quality is the JPEG quality, from 1 to 100.
+	Quality int `yaml:"quality"`
 }
 
 type TransportConfig struct {
 	ListenAddr string `yaml:"listen_addr"`
 	Nodes      []string
+	// ReceiveMTU is the size of the buffers used for reading packets from
+	// other nodes. Larger packets are dropped. The
+	// servertransport.DefaultReceiveMTU is used when zero.
+	ReceiveMTU int `yaml:"receive_mtu"`
 }
 
 type PrometheusConfig struct {
 	AccessToken string `yaml:"access_token"`
 }
 
+type AdminConfig struct {
+	// AccessToken protects the admin API. The API is disabled when empty.
+	AccessToken string `yaml:"access_token"`
+	// DrainTimeout is the maximum time to wait for clients to leave a room
+	// being migrated to another node.
+	DrainTimeout time.Duration `yaml:"drain_timeout"`
+	// MigrationOrigins contains the host patterns of the nodes that migrate
+	// rooms to this node, for example "*.example.com". The migrated clients
+	// connect from pages served by those nodes.
+	MigrationOrigins []string `yaml:"migration_origins"`
+}
+
+type ReplicationRole string
+
+const (
+	ReplicationRoleActive  ReplicationRole = "active"
+	ReplicationRoleStandby ReplicationRole = "standby"
+)
+
+type ReplicationConfig struct {
+	// Role of this node. Replication is disabled when empty.
+	Role ReplicationRole `yaml:"role"`
+	// PeerURL is the base URL of the standby node. It is required for the
+	// active node.
+	PeerURL string `yaml:"peer_url"`
+	// Interval between two snapshots sent to the standby node.
+	Interval time.Duration `yaml:"interval"`
+	// AutoFailover enables the promotion of the standby node when it stops
+	// receiving snapshots. The standby node can always be promoted through the
+	// admin API.
+	AutoFailover bool `yaml:"auto_failover"`
+	// FailoverTimeout is the time after which the standby node promotes itself
+	// when it stops receiving snapshots. Only used with AutoFailover.
+	FailoverTimeout time.Duration `yaml:"failover_timeout"`
+}
+
 type Config struct {
 	BaseURL  string `yaml:"base_url"`
 	BindHost string `yaml:"bind_host"`
@@ -86,6 +166,9 @@ type Config struct {
 	Store      StoreConfig      `yaml:"store"`
 	Network    NetworkConfig    `yaml:"network"`
 	Prometheus PrometheusConfig `yaml:"prometheus"`
+	Admin      AdminConfig      `yaml:"admin"`
+
+	Replication ReplicationConfig `yaml:"replication"`
 
 	Frontend Frontend `yaml:"frontend"`
 }
@@ -112,4 +195,7 @@ type ClientConfig struct {
 type PeerConfig struct {
 	ICEServers               []ICEAuthServer `json:"iceServers"`
 	EncodedInsertableStreams bool            `json:"encodedInsertableStreams"`
+	// Simulcast contains the encodings publishers should send. It is only set
+	// for SFU.
+	Simulcast sfu.SimulcastLadder `json:"simulcast,omitempty"`
 }
@@ -50,7 +50,7 @@ func NewMeshHandler(log logger.Logger, wss *WSS) http.Handler {
 				adapter.SetMetadata(clientID, "")
 			case message.TypeReady:
 				ready := *msg.Payload.Ready
-				adapter.SetMetadata(clientID, ready.Nickname)
+				adapter.SetMetadata(clientID, readyMetadata(ready.Nickname, websocketCtx.RestoredMetadata()))
 
 				clients, readyClientsErr := getReadyClients(adapter)
 				if readyClientsErr != nil {
@@ -136,9 +136,21 @@ func mustReadWS(t *testing.T, ctx context.Context, ws *websocket.Conn) message.M
 	return msg
 }
 
+// mustReadWSType skips the messages until it reads one of type typ.
+func mustReadWSType(t *testing.T, ctx context.Context, ws *websocket.Conn, typ message.Type) message.Message {
+	t.Helper()
+
+	for {
+		msg := mustReadWS(t, ctx, ws)
+		if msg.Type == typ {
+			return msg
+		}
+	}
+}
+
 func setupMeshServer(rooms server.RoomManager) (s *httptest.Server, url string) {
 	log := logger.New()
-	handler := server.NewMeshHandler(log, server.NewWSS(log, rooms))
+	handler := server.NewMeshHandler(log, server.NewWSS(log, rooms, nil))
 	s = httptest.NewServer(handler)
 	url = "ws" + strings.TrimPrefix(s.URL, "http") + "/ws/" + roomName.String() + "/" + clientID.String()
 	return
@@ -223,3 +235,62 @@ func TestMesh_event_signal(t *testing.T) {
 	assert.Equal(t, signal, emit.message.Payload.Signal.Signal)
 	assert.Equal(t, clientID, emit.message.Payload.Signal.PeerID)
 }
+
+func TestMesh_event_ready_restoredMetadata(t *testing.T) {
+	rooms := newMemoryRoomManager()
+
+	rooms.Restore([]server.RoomSnapshot{{
+		RoomID: roomName,
+		Clients: map[identifiers.ClientID]string{
+			clientID: "restored",
+		},
+		Timestamp: time.Now(),
+	}}, time.Minute)
+
+	adapter, _ := rooms.Enter(roomName)
+	defer rooms.Exit(roomName)
+
+	srv, url := setupMeshServer(rooms)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ws1 := mustDialWS(t, ctx, url)
+	defer ws1.Close(websocket.StatusGoingAway, "")
+
+	// The restored metadata must not mark the client as ready before it sends
+	// the ready message.
+	require.Eventually(t, func() bool {
+		clients, err := adapter.Clients()
+		require.NoError(t, err)
+
+		metadata, ok := clients[clientID]
+
+		return ok && metadata == ""
+	}, timeout, 10*time.Millisecond)
+
+	ws2 := mustDialWS(t, ctx, strings.TrimSuffix(url, clientID.String())+clientID2.String())
+	defer ws2.Close(websocket.StatusGoingAway, "")
+
+	mustWriteWS(t, ctx, ws2, message.NewReady(roomName, message.Ready{
+		Nickname: "nick2",
+	}))
+
+	msg := mustReadWSType(t, ctx, ws2, message.TypeUsers)
+	assert.Equal(t, map[identifiers.ClientID]string{
+		clientID2: "nick2",
+	}, msg.Payload.Users.Nicknames)
+
+	_ = mustReadWSType(t, ctx, ws1, message.TypeUsers)
+
+	mustWriteWS(t, ctx, ws1, message.NewReady(roomName, message.Ready{
+		Nickname: "",
+	}))
+
+	msg = mustReadWSType(t, ctx, ws1, message.TypeUsers)
+	assert.Equal(t, map[identifiers.ClientID]string{
+		clientID:  "restored",
+		clientID2: "nick2",
+	}, msg.Payload.Users.Nicknames)
+}
@@ -51,6 +51,18 @@ func (m Message) MarshalJSON() ([]byte, error) {
 	case TypeUsers:
 		payload, err = json.Marshal(m.Payload.Users)
 		err = errors.Trace(err)
+	case TypeMigrate:
+		payload, err = json.Marshal(m.Payload.Migrate)
+		err = errors.Trace(err)
+	case TypeMute:
+		payload, err = json.Marshal(m.Payload.Mute)
+		err = errors.Trace(err)
+	case TypeMicHint:
+		payload, err = json.Marshal(m.Payload.MicHint)
+		err = errors.Trace(err)
+	case TypePeerState:
+		payload, err = json.Marshal(m.Payload.PeerState)
+		err = errors.Trace(err)
 	default:
 		err = errors.Annotatef(ErrUnknownMessageType, "message: %+v", m)
 	}
@@ -115,6 +127,22 @@ func (m *Message) UnmarshalJSON(b []byte) error {
 		m.Payload.Users = &Users{}
 		err = json.Unmarshal(j.Payload, m.Payload.Users)
 		err = errors.Trace(err)
+	case TypeMigrate:
+		m.Payload.Migrate = &Migrate{}
+		err = json.Unmarshal(j.Payload, m.Payload.Migrate)
+		err = errors.Trace(err)
+	case TypeMute:
+		m.Payload.Mute = &Mute{}
+		err = json.Unmarshal(j.Payload, m.Payload.Mute)
+		err = errors.Trace(err)
+	case TypeMicHint:
+		m.Payload.MicHint = &MicHint{}
+		err = json.Unmarshal(j.Payload, m.Payload.MicHint)
+		err = errors.Trace(err)
+	case TypePeerState:
+		m.Payload.PeerState = &PeerState{}
+		err = json.Unmarshal(j.Payload, m.Payload.PeerState)
+		err = errors.Trace(err)
 	default:
 		err = errors.Trace(ErrUnknownMessageType)
 	}
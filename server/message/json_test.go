@@ -158,6 +158,44 @@ func TestMessage_JSON(t *testing.T) {
 				},
 			},
 		},
+		{
+			Type: message.TypeMigrate,
+			Room: "test",
+			Payload: message.Payload{
+				Migrate: &message.Migrate{
+					URL:         "https://node2.example.com",
+					ResumeToken: "token123",
+				},
+			},
+		},
+		{
+			Type: message.TypeMute,
+			Room: "test",
+			Payload: message.Payload{
+				Mute: &message.Mute{
+					Muted: true,
+				},
+			},
+		},
+		{
+			Type: message.TypeMicHint,
+			Room: "test",
+			Payload: message.Payload{
+				MicHint: &message.MicHint{
+					Type: message.MicHintTypeTalkingWhileMuted,
+				},
+			},
+		},
+		{
+			Type: message.TypePeerState,
+			Room: "test",
+			Payload: message.Payload{
+				PeerState: &message.PeerState{
+					From: "connected",
+					To:   "reconnecting",
+				},
+			},
+		},
 	}
 
 	for _, m := range messages {
@@ -95,6 +95,36 @@ func NewSignal(roomID identifiers.RoomID, payload UserSignal) Message {
 	}
 }
 
+func NewMigrate(roomID identifiers.RoomID, payload Migrate) Message {
+	return Message{
+		Type: TypeMigrate,
+		Room: roomID,
+		Payload: Payload{
+			Migrate: &payload,
+		},
+	}
+}
+
+func NewMicHint(roomID identifiers.RoomID, payload MicHint) Message {
+	return Message{
+		Type: TypeMicHint,
+		Room: roomID,
+		Payload: Payload{
+			MicHint: &payload,
+		},
+	}
+}
+
+func NewPeerState(roomID identifiers.RoomID, payload PeerState) Message {
+	return Message{
+		Type: TypePeerState,
+		Room: roomID,
+		Payload: Payload{
+			PeerState: &payload,
+		},
+	}
+}
+
 type UserSignal struct {
 	PeerID identifiers.ClientID `json:"peerId"`
 	Signal Signal               `json:"signal"`
@@ -120,6 +150,20 @@ type Payload struct {
 	// Users is sent as a response to Ready.
 	// TODO use PubTrack instead.
 	Users *Users
+
+	// Migrate is sent to the clients when the room is moved to another node.
+	Migrate *Migrate
+
+	// Mute is sent from the client to the server whenever the user mutes or
+	// unmutes the microphone.
+	Mute *Mute
+	// MicHint is sent only to the client whose microphone activity does not
+	// match its mute state.
+	MicHint *MicHint
+
+	// PeerState is sent to the client whenever the state of its peer
+	// connection to the server changes.
+	PeerState *PeerState
 }
 
 type RoomJoin struct {
@@ -142,6 +186,13 @@ const (
 	TypeRoomLeave Type = "wsRoomLeave"
 
 	TypeUsers Type = "users"
+
+	TypeMigrate Type = "migrate"
+
+	TypeMute    Type = "mute"
+	TypeMicHint Type = "micHint"
+
+	TypePeerState Type = "peerState"
 )
 
 type HangUp struct {
@@ -189,6 +240,43 @@ type PubTrack struct {
 	Type transport.TrackEventType `json:"type"`
 }
 
+// Migrate instructs the client to reconnect to another node.
+type Migrate struct {
+	// URL is the base URL of the node the client should reconnect to.
+	URL string `json:"url"`
+	// ResumeToken should be sent by the client when reconnecting to resume
+	// the session. It is empty for clients that joined during the drain.
+	ResumeToken string `json:"resumeToken,omitempty"`
+}
+
+// Mute describes the microphone state of the client.
+type Mute struct {
+	Muted bool `json:"muted"`
+}
+
+type MicHintType string
+
+const (
+	// MicHintTypeTalkingWhileMuted is sent when sustained speech is detected
+	// while the client is muted.
+	MicHintTypeTalkingWhileMuted MicHintType = "talkingWhileMuted"
+	// MicHintTypeSilent is sent when sustained silence is detected while the
+	// client is not muted.
+	MicHintTypeSilent MicHintType = "silent"
+)
+
+// MicHint is an advisory event. Clients might display a notification, but
+// should not change the mute state on their own.
+type MicHint struct {
+	Type MicHintType `json:"type"`
+}
+
+// PeerState describes a transition of the server-side peer connection.
+type PeerState struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
 type SubTrack struct {
 	TrackID     identifiers.TrackID  `json:"trackId"`
 	PubClientID identifiers.ClientID `json:"pubClientId"`
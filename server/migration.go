@@ -0,0 +1,308 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/logger"
+	"github.com/peer-calls/peer-calls/v4/server/message"
+	"github.com/peer-calls/peer-calls/v4/server/uuid"
+)
+
+const (
+	defaultDrainTimeout      = 30 * time.Second
+	defaultDrainPollInterval = time.Second
+)
+
+var (
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrRoomMigrating = errors.New("room is already being migrated")
+)
+
+// roomDrainer is implemented by RoomManagers that can mark rooms as being
+// migrated away.
+type roomDrainer interface {
+	SetDraining(room identifiers.RoomID, targetURL string)
+	Draining(room identifiers.RoomID) (string, bool)
+}
+
+// MigratableRoomManager is a RoomManager whose rooms can be migrated between
+// nodes.
+type MigratableRoomManager interface {
+	RoomManager
+	RoomSnapshotter
+	metadataRestorer
+	roomDrainer
+}
+
+type MigratorParams struct {
+	Log   logger.Logger
+	Rooms MigratableRoomManager
+	// AccessToken is used to authenticate against the admin API of the target
+	// node.
+	AccessToken string
+	// HTTPClient is used to call the target node. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// DrainTimeout is the maximum time to wait for all clients to leave the
+	// room on the source node.
+	DrainTimeout time.Duration
+	// ResumeTTL defines for how long the clients can resume their sessions on
+	// the target node.
+	ResumeTTL time.Duration
+}
+
+// Migrator moves rooms between nodes without ending the calls. The target
+// node pre-creates the room state, after which the clients are instructed to
+// reconnect to the target node using session resume tokens, and the source
+// node waits for the room to drain.
+type Migrator struct {
+	params *MigratorParams
+
+	mu sync.Mutex
+	// migrating contains the rooms being migrated away from this node.
+	migrating map[identifiers.RoomID]struct{}
+	wg        sync.WaitGroup
+}
+
+func NewMigrator(params MigratorParams) *Migrator {
+	params.Log = params.Log.WithNamespaceAppended("migrator")
+
+	if params.HTTPClient == nil {
+		params.HTTPClient = http.DefaultClient
+	}
+
+	if params.DrainTimeout == 0 {
+		params.DrainTimeout = defaultDrainTimeout
+	}
+
+	if params.ResumeTTL == 0 {
+		params.ResumeTTL = defaultSnapshotTTL
+	}
+
+	return &Migrator{
+		params:    &params,
+		migrating: map[identifiers.RoomID]struct{}{},
+	}
+}
+
+// MigrationResult contains the outcome of a migration on the source node.
+type MigrationResult struct {
+	// NumMigrated is the number of clients instructed to reconnect.
+	NumMigrated int `json:"numMigrated"`
+}
+
+// Prepare is called on the target node. It pre-creates the room state so
+// that the migrated clients can resume their sessions.
+func (m *Migrator) Prepare(snapshot RoomSnapshot) {
+	m.params.Log.Info("Prepare room migration", logger.Ctx{
+		"room_id":     snapshot.RoomID,
+		"num_clients": len(snapshot.Clients),
+	})
+
+	snapshot.Timestamp = time.Now()
+
+	m.params.Rooms.Restore([]RoomSnapshot{snapshot}, m.params.ResumeTTL)
+}
+
+// Migrate is called on the source node. It prepares the target node and
+// instructs the clients to reconnect to it. The room is drained in the
+// background until it is empty or the drain timeout expires, see Wait.
+func (m *Migrator) Migrate(
+	ctx context.Context,
+	room identifiers.RoomID,
+	targetURL string,
+) (MigrationResult, error) {
+	var result MigrationResult
+
+	if !m.startMigration(room) {
+		return result, errors.Annotatef(ErrRoomMigrating, "migrate: %s", room)
+	}
+
+	log := m.params.Log.WithCtx(logger.Ctx{
+		"room_id":    room,
+		"target_url": targetURL,
+	})
+
+	adapter, _ := m.params.Rooms.Enter(room)
+
+	done := func() {
+		m.params.Rooms.Exit(room)
+		m.endMigration(room)
+	}
+
+	snapshot, err := m.snapshot(room, adapter)
+	if err != nil {
+		done()
+
+		return result, errors.Annotatef(err, "migrate: %s", room)
+	}
+
+	snapshot.ResumeTokens = make(map[identifiers.ClientID]string, len(snapshot.Clients))
+
+	for clientID := range snapshot.Clients {
+		snapshot.ResumeTokens[clientID] = uuid.New()
+	}
+
+	log.Info("Migrate room", logger.Ctx{
+		"num_clients": len(snapshot.Clients),
+	})
+
+	if err := m.prepareTarget(ctx, targetURL, snapshot); err != nil {
+		done()
+
+		return result, errors.Annotatef(err, "prepare target: %s", targetURL)
+	}
+
+	m.params.Rooms.SetDraining(room, targetURL)
+
+	for clientID, resumeToken := range snapshot.ResumeTokens {
+		err := adapter.Emit(clientID, message.NewMigrate(room, message.Migrate{
+			URL:         targetURL,
+			ResumeToken: resumeToken,
+		}))
+		if err != nil {
+			log.Error("Emit migrate", errors.Trace(err), logger.Ctx{
+				"client_id": clientID,
+			})
+
+			continue
+		}
+
+		result.NumMigrated++
+	}
+
+	m.wg.Add(1)
+
+	go func() {
+		defer m.wg.Done()
+		defer done()
+		defer m.params.Rooms.SetDraining(room, "")
+
+		remaining, err := m.drain(adapter)
+		if err != nil {
+			log.Error("Drain room", errors.Trace(err), nil)
+		}
+
+		log.Info("Room drained", logger.Ctx{
+			"num_migrated":  result.NumMigrated,
+			"num_remaining": remaining,
+		})
+	}()
+
+	return result, nil
+}
+
+// Wait blocks until all migrated rooms have been drained.
+func (m *Migrator) Wait() {
+	m.wg.Wait()
+}
+
+// startMigration returns false when the room is already being migrated.
+func (m *Migrator) startMigration(room identifiers.RoomID) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.migrating[room]; ok {
+		return false
+	}
+
+	m.migrating[room] = struct{}{}
+
+	return true
+}
+
+func (m *Migrator) endMigration(room identifiers.RoomID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.migrating, room)
+}
+
+// snapshot contains only the clients currently connected to the room.
+// Restored clients that have not reconnected are left out since they cannot
+// be instructed to migrate.
+func (m *Migrator) snapshot(room identifiers.RoomID, adapter Adapter) (RoomSnapshot, error) {
+	clients, err := adapter.Clients()
+	if err != nil {
+		return RoomSnapshot{}, errors.Annotate(err, "clients")
+	}
+
+	if len(clients) == 0 {
+		return RoomSnapshot{}, errors.Trace(ErrRoomNotFound)
+	}
+
+	return RoomSnapshot{
+		RoomID:    room,
+		Clients:   clients,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+func (m *Migrator) prepareTarget(ctx context.Context, targetURL string, snapshot RoomSnapshot) error {
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Annotate(err, "marshal snapshot")
+	}
+
+	prepareURL := strings.TrimSuffix(targetURL, "/") +
+		"/api/rooms/" + url.PathEscape(snapshot.RoomID.String()) + "/prepare"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, prepareURL, bytes.NewReader(b))
+	if err != nil {
+		return errors.Annotate(err, "new request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.params.AccessToken)
+
+	res, err := m.params.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Annotatef(err, "post %s", prepareURL)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("post %s: unexpected status code: %d", prepareURL, res.StatusCode)
+	}
+
+	return nil
+}
+
+// drain waits until there are no more clients in the room and returns the
+// number of remaining clients.
+func (m *Migrator) drain(adapter Adapter) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.params.DrainTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(defaultDrainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		size, err := adapter.Size()
+		if err != nil {
+			return 0, errors.Annotate(err, "size")
+		}
+
+		// The size of the room also includes clients that joined while the room
+		// was draining, which is fine since they have been redirected too.
+		if size == 0 {
+			return 0, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return size, nil
+		}
+	}
+}
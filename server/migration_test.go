@@ -0,0 +1,243 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/message"
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+)
+
+const adminAccessToken = "admin1234"
+
+// newMigrationNode starts a node serving the admin API and the mesh
+// websocket handler. Websocket connections from pages served by other hosts
+// are accepted when they match originPatterns.
+func newMigrationNode(
+	t *testing.T,
+	originPatterns ...string,
+) (*server.AdapterRoomManager, *server.Migrator, *httptest.Server) {
+	t.Helper()
+
+	log := test.NewLogger()
+
+	rooms := newMemoryRoomManager()
+
+	migrator := server.NewMigrator(server.MigratorParams{
+		Log:          log,
+		Rooms:        rooms,
+		AccessToken:  adminAccessToken,
+		DrainTimeout: 10 * time.Millisecond,
+	})
+
+	api := server.NewAPIHandler(server.APIHandlerParams{
+		Log:         log,
+		AccessToken: adminAccessToken,
+		Migrator:    migrator,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", http.StripPrefix("/api", api))
+	mux.Handle("/ws/", server.NewMeshHandler(log, server.NewWSS(log, rooms, originPatterns)))
+
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return rooms, migrator, s
+}
+
+func TestMigrator_Migrate(t *testing.T) {
+	sourceRooms, sourceMigrator, _ := newMigrationNode(t)
+	targetRooms, _, target := newMigrationNode(t)
+
+	adapter, _ := sourceRooms.Enter("room1")
+	defer sourceRooms.Exit("room1")
+
+	mockWriter := NewMockWriter()
+	client := server.NewClientWithID(mockWriter, "a")
+
+	defer client.Close(websocket.StatusNormalClosure, "")
+
+	client.SetMetadata("nick-a")
+	require.NoError(t, adapter.Add(client))
+
+	// room join message
+	<-mockWriter.out
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result, err := sourceMigrator.Migrate(ctx, "room1", target.URL)
+	require.NoError(t, err)
+	assert.Equal(t, server.MigrationResult{
+		NumMigrated: 1,
+	}, result)
+
+	_, err = sourceMigrator.Migrate(ctx, "room1", target.URL)
+	assert.True(t, multierr.Is(err, server.ErrRoomMigrating), "%+v", err)
+
+	// The client never leaves so the drain times out.
+	sourceMigrator.Wait()
+
+	var serializer server.ByteSerializer
+
+	msg, err := serializer.Deserialize(<-mockWriter.out)
+	require.NoError(t, err)
+	require.Equal(t, message.TypeMigrate, msg.Type)
+	assert.Equal(t, target.URL, msg.Payload.Migrate.URL)
+	assert.NotEmpty(t, msg.Payload.Migrate.ResumeToken)
+
+	_, ok := targetRooms.RestoredMetadata("room1", "a", "invalid")
+	assert.False(t, ok, "invalid resume token should not restore the session")
+
+	metadata, ok := targetRooms.RestoredMetadata("room1", "a", msg.Payload.Migrate.ResumeToken)
+	assert.True(t, ok)
+	assert.Equal(t, "nick-a", metadata)
+
+	_, ok = sourceRooms.Draining("room1")
+	assert.False(t, ok, "room should no longer be draining after migration")
+}
+
+func TestMigrator_Migrate_crossHost(t *testing.T) {
+	sourceRooms, sourceMigrator, source := newMigrationNode(t)
+
+	sourceURL, err := url.Parse(source.URL)
+	require.NoError(t, err)
+
+	// httptest servers listen on different ports, so the target has a different
+	// host than the source.
+	_, _, target := newMigrationNode(t, sourceURL.Host)
+	_, _, otherTarget := newMigrationNode(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	wsURL := func(baseURL string) string {
+		return "ws" + strings.TrimPrefix(baseURL, "http") + "/ws/room1/a"
+	}
+
+	originOptions := &websocket.DialOptions{
+		HTTPHeader: http.Header{
+			"Origin": []string{source.URL},
+		},
+	}
+
+	sourceWS, _, err := websocket.Dial(ctx, wsURL(source.URL), originOptions)
+	require.NoError(t, err)
+
+	defer sourceWS.Close(websocket.StatusNormalClosure, "")
+
+	mustWriteWS(t, ctx, sourceWS, message.NewReady("room1", message.Ready{
+		Nickname: "nick-a",
+	}))
+	mustReadWSType(t, ctx, sourceWS, message.TypeUsers)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		source.URL+"/api/rooms/room1/migrate",
+		strings.NewReader(`{"targetUrl":"`+target.URL+`"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminAccessToken)
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	var result server.MigrationResult
+
+	err = json.NewDecoder(res.Body).Decode(&result)
+	res.Body.Close()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusAccepted, res.StatusCode, "should not wait for the room to drain")
+	assert.Equal(t, server.MigrationResult{NumMigrated: 1}, result)
+
+	msg := mustReadWSType(t, ctx, sourceWS, message.TypeMigrate)
+	resumeQuery := "?resumeToken=" + url.QueryEscape(msg.Payload.Migrate.ResumeToken)
+
+	_, res, err = websocket.Dial(ctx, wsURL(otherTarget.URL)+resumeQuery, originOptions)
+	require.Error(t, err, "should reject origins that do not match the patterns")
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	targetWS, _, err := websocket.Dial(ctx, wsURL(msg.Payload.Migrate.URL)+resumeQuery, originOptions)
+	require.NoError(t, err)
+
+	defer targetWS.Close(websocket.StatusNormalClosure, "")
+
+	require.NoError(t, sourceWS.Close(websocket.StatusNormalClosure, ""))
+
+	mustWriteWS(t, ctx, targetWS, message.NewReady("room1", message.Ready{
+		Nickname: "",
+	}))
+
+	msg = mustReadWSType(t, ctx, targetWS, message.TypeUsers)
+	assert.Equal(t, map[identifiers.ClientID]string{
+		"a": "nick-a",
+	}, msg.Payload.Users.Nicknames)
+
+	sourceMigrator.Wait()
+
+	_, ok := sourceRooms.Draining("room1")
+	assert.False(t, ok, "room should no longer be draining after migration")
+}
+
+func TestMigrator_Migrate_roomNotFound(t *testing.T) {
+	_, sourceMigrator, source := newMigrationNode(t)
+	_, _, target := newMigrationNode(t)
+
+	_, err := sourceMigrator.Migrate(context.Background(), "room1", target.URL)
+	assert.True(t, multierr.Is(err, server.ErrRoomNotFound), "%+v", err)
+
+	req, err := http.NewRequest(
+		http.MethodPost,
+		source.URL+"/api/rooms/room1/migrate",
+		strings.NewReader(`{"targetUrl":"`+target.URL+`"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminAccessToken)
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestMigrator_Migrate_onlyRestoredClients(t *testing.T) {
+	sourceRooms, sourceMigrator, _ := newMigrationNode(t)
+	targetRooms, _, target := newMigrationNode(t)
+
+	sourceRooms.Restore([]server.RoomSnapshot{{
+		RoomID:    "room1",
+		Clients:   map[identifiers.ClientID]string{"a": "nick-a"},
+		Timestamp: time.Now(),
+	}}, time.Minute)
+
+	_, err := sourceMigrator.Migrate(context.Background(), "room1", target.URL)
+	assert.True(t, multierr.Is(err, server.ErrRoomNotFound), "%+v", err)
+
+	_, ok := sourceRooms.Draining("room1")
+	assert.False(t, ok, "room without live clients should not be drained")
+
+	assert.Empty(t, targetRooms.Snapshot(), "clients that have not reconnected should not be migrated")
+}
+
+func TestAPIHandler_unauthorized(t *testing.T) {
+	_, _, s := newMigrationNode(t)
+
+	res, err := http.Post(s.URL+"/api/rooms/room1/prepare", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
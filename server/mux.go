@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/url"
 	"path"
-	"strings"
 
 	"github.com/go-chi/chi"
 	"github.com/peer-calls/peer-calls/v4/server/identifiers"
@@ -17,6 +16,7 @@ import (
 	"github.com/peer-calls/peer-calls/v4/server/uuid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"nhooyr.io/websocket"
 )
 
 func buildManifest(baseURL string) []byte {
@@ -43,12 +43,23 @@ type Mux struct {
 	network                  NetworkConfig
 	version                  string
 	encodedInsertableStreams bool
+	simulcastLadders         *sfu.SimulcastLadders
+	// sfuHandler is nil unless the network type is sfu.
+	sfuHandler *SFU
 }
 
 func (mux *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	mux.handler.ServeHTTP(w, r)
 }
 
+// Close releases the resources of the websocket handler. It should be called
+// after the server has stopped.
+func (mux *Mux) Close() {
+	if mux.sfuHandler != nil {
+		mux.sfuHandler.Close()
+	}
+}
+
 type TracksManager interface {
 	Add(room identifiers.RoomID, transport transport.Transport) (<-chan pubsub.PubTrackEvent, error)
 	Sub(params sfu.SubParams) error
@@ -74,10 +85,14 @@ func NewMux(
 	network NetworkConfig,
 	iceServers []ICEServer,
 	encodedInsertableStreams bool,
+	originPatterns []string,
 	rooms RoomManager,
 	tracks TracksManager,
+	simulcastLadders *sfu.SimulcastLadders,
+	replicator *Replicator,
 	prom PrometheusConfig,
 	embed Embed,
+	api http.Handler,
 ) *Mux {
 	log = log.WithNamespaceAppended("mux")
 
@@ -92,6 +107,7 @@ func NewMux(
 		network:                  network,
 		version:                  version,
 		encodedInsertableStreams: encodedInsertableStreams,
+		simulcastLadders:         simulcastLadders,
 	}
 
 	var root string
@@ -101,14 +117,28 @@ func NewMux(
 		root = baseURL
 	}
 
+	wss := NewWSS(log, rooms, originPatterns)
+
 	wsHandler := newWebSocketHandler(
 		log,
 		network,
-		NewWSS(log, rooms),
+		wss,
 		iceServers,
 		tracks,
 	)
 
+	if replicator != nil {
+		// Clients of a node that stepped down should reconnect to the promoted
+		// node through the load balancer.
+		replicator.OnDemote(func() {
+			wss.CloseAll(websocket.StatusServiceRestart, "node stepped down")
+		})
+	}
+
+	if sfuHandler, ok := wsHandler.(*SFU); ok {
+		mux.sfuHandler = sfuHandler
+	}
+
 	manifest := buildManifest(baseURL)
 	handler.Route(root, func(router chi.Router) {
 		router.Get("/", withGauge(prometheusHomeViewsTotal, renderer.Render(mux.routeIndex)))
@@ -122,6 +152,14 @@ func NewMux(
 		})
 		router.Get("/probes/health", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
+
+			// Standby nodes should not receive any traffic until promoted.
+			if replicator != nil && !replicator.Healthy() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
 			w.WriteHeader(http.StatusOK)
 		})
 		router.Get("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
@@ -129,12 +167,7 @@ func NewMux(
 			w.Write(manifest)
 		})
 		router.Get("/metrics", func(w http.ResponseWriter, r *http.Request) {
-			accessToken := r.Header.Get("Authorization")
-			if strings.HasPrefix(accessToken, "Bearer ") {
-				accessToken = accessToken[len("Bearer "):]
-			} else {
-				accessToken = r.FormValue("access_token")
-			}
+			accessToken := accessTokenFromRequest(r)
 
 			if accessToken == "" || accessToken != prom.AccessToken {
 				w.WriteHeader(http.StatusUnauthorized)
@@ -144,7 +177,21 @@ func NewMux(
 			promhttp.Handler().ServeHTTP(w, r)
 		})
 
-		router.Mount("/ws", wsHandler)
+		router.Mount("/ws", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Unhealthy nodes must not accept clients, otherwise both nodes might
+			// serve the same room.
+			if replicator != nil && !replicator.Healthy() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			wsHandler.ServeHTTP(w, r)
+		}))
+
+		if api != nil {
+			router.Mount("/api", api)
+		}
 	})
 
 	return mux
@@ -220,6 +267,12 @@ func (mux *Mux) routeCall(w http.ResponseWriter, r *http.Request) (string, inter
 		Network: mux.network.Type,
 	}
 
+	if mux.network.Type == NetworkTypeSFU && mux.simulcastLadders != nil {
+		room := identifiers.RoomID(path.Base(r.URL.Path))
+		// Simulcast is disabled when the ladder is empty.
+		config.PeerConfig.Simulcast = mux.simulcastLadders.Ladder(room)
+	}
+
 	configJSON, _ := json.Marshal(config)
 
 	return "call.html", string(configJSON), nil
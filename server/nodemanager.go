@@ -15,6 +15,8 @@ import (
 const (
 	pingTimeout    = 3 * time.Second
 	destroyTimeout = 10 * time.Second
+	// maxReceiveMTU is the largest packet that fits into packetio.Buffer.
+	maxReceiveMTU = 0xFFFF
 )
 
 type NodeManager struct {
@@ -30,6 +32,8 @@ type NodeManagerParams struct {
 	TracksManager TracksManager
 	ListenAddr    *net.UDPAddr
 	Nodes         []*net.UDPAddr
+	// ReceiveMTU is optional.
+	ReceiveMTU int
 }
 
 func NewNodeManager(params NodeManagerParams) (*NodeManager, error) {
@@ -37,6 +41,10 @@ func NewNodeManager(params NodeManagerParams) (*NodeManager, error) {
 		"local_addr": params.ListenAddr,
 	})
 
+	if params.ReceiveMTU < 0 || params.ReceiveMTU > maxReceiveMTU {
+		return nil, errors.Errorf("invalid receive MTU: %d", params.ReceiveMTU)
+	}
+
 	conn, err := net.ListenUDP("udp", params.ListenAddr)
 	if err != nil {
 		return nil, errors.Annotatef(err, "listen udp: %s", params.ListenAddr)
@@ -58,6 +66,7 @@ func NewNodeManager(params NodeManagerParams) (*NodeManager, error) {
 		PingTimeout:         pingTimeout,
 		DestroyTimeout:      destroyTimeout,
 		InterceptorRegistry: interceptorRegistry,
+		ReceiveMTU:          params.ReceiveMTU,
 	})
 
 	nm := &NodeManager{
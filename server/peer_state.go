@@ -0,0 +1,214 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/pion/webrtc/v3"
+)
+
+var ErrInvalidPeerStateTransition = errors.New("invalid peer state transition")
+
+// PeerState is the state of a server-side peer connection.
+type PeerState int
+
+const (
+	PeerStateNew PeerState = iota
+	PeerStateConnecting
+	PeerStateConnected
+	PeerStateReconnecting
+	PeerStateClosed
+)
+
+func (s PeerState) String() string {
+	switch s {
+	case PeerStateNew:
+		return "new"
+	case PeerStateConnecting:
+		return "connecting"
+	case PeerStateConnected:
+		return "connected"
+	case PeerStateReconnecting:
+		return "reconnecting"
+	case PeerStateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// peerStateTransitions contains the allowed transitions. A peer can be closed
+// from any state, but it can never leave the closed state.
+var peerStateTransitions = map[PeerState][]PeerState{
+	PeerStateNew:          {PeerStateConnecting, PeerStateConnected, PeerStateClosed},
+	PeerStateConnecting:   {PeerStateConnected, PeerStateClosed},
+	PeerStateConnected:    {PeerStateReconnecting, PeerStateClosed},
+	PeerStateReconnecting: {PeerStateConnected, PeerStateClosed},
+}
+
+// PeerStateTransition is emitted whenever the state of a peer changes.
+type PeerStateTransition struct {
+	From      PeerState
+	To        PeerState
+	Timestamp time.Time
+}
+
+// PeerStateMachine tracks the state of a peer connection. Listeners are
+// notified of each transition in order, and the transition to
+// PeerStateClosed is guaranteed to be emitted exactly once. It is safe for
+// concurrent use.
+type PeerStateMachine struct {
+	mu sync.Mutex
+
+	state     PeerState
+	listeners []func(PeerStateTransition)
+
+	// notifyMu ensures that the listeners receive the transitions in order.
+	notifyMu sync.Mutex
+}
+
+// NewPeerStateMachine creates a new instance of PeerStateMachine in the
+// PeerStateNew state.
+func NewPeerStateMachine() *PeerStateMachine {
+	return &PeerStateMachine{
+		state: PeerStateNew,
+	}
+}
+
+// State returns the current state.
+func (m *PeerStateMachine) State() PeerState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
+
+// OnTransition registers a listener. The listener is called synchronously
+// and must not call Transition.
+func (m *PeerStateMachine) OnTransition(listener func(PeerStateTransition)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.listeners = append(m.listeners, listener)
+}
+
+// Transition changes the state and notifies the listeners. It returns
+// ErrInvalidPeerStateTransition when the transition is not allowed.
+func (m *PeerStateMachine) Transition(to PeerState) error {
+	m.notifyMu.Lock()
+	defer m.notifyMu.Unlock()
+
+	m.mu.Lock()
+
+	from := m.state
+
+	if !isValidPeerStateTransition(from, to) {
+		m.mu.Unlock()
+
+		return errors.Annotatef(ErrInvalidPeerStateTransition, "%s -> %s", from, to)
+	}
+
+	m.state = to
+
+	listeners := m.listeners
+
+	m.mu.Unlock()
+
+	prometheusWebRTCPeerStateTransitions.WithLabelValues(from.String(), to.String()).Inc()
+
+	transition := PeerStateTransition{
+		From:      from,
+		To:        to,
+		Timestamp: time.Now(),
+	}
+
+	for _, listener := range listeners {
+		listener(transition)
+	}
+
+	return nil
+}
+
+// HandleICEConnectionState performs the transition that corresponds to the
+// ICE connection state, if any. It returns the new state.
+func (m *PeerStateMachine) HandleICEConnectionState(iceState webrtc.ICEConnectionState) PeerState {
+	var to PeerState
+
+	switch iceState {
+	case webrtc.ICEConnectionStateChecking:
+		to = PeerStateConnecting
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		to = PeerStateConnected
+	case webrtc.ICEConnectionStateDisconnected:
+		// ICE might still recover from a disconnect. It will switch to the failed
+		// state if it does not.
+		to = PeerStateReconnecting
+	case webrtc.ICEConnectionStateFailed, webrtc.ICEConnectionStateClosed:
+		to = PeerStateClosed
+	default:
+		return m.State()
+	}
+
+	// Some of the ICE states do not map to a valid transition, for example
+	// checking after a disconnect, which we can safely ignore.
+	_ = m.Transition(to)
+
+	return m.State()
+}
+
+// peerStateQueue hands the transitions of a PeerStateMachine off to a single
+// goroutine, so that slow listeners do not block the ICE callbacks that
+// perform the transitions. The transitions are handled in order.
+type peerStateQueue struct {
+	mu          sync.Mutex
+	transitions []PeerStateTransition
+	signal      chan struct{}
+}
+
+func newPeerStateQueue() *peerStateQueue {
+	return &peerStateQueue{
+		signal: make(chan struct{}, 1),
+	}
+}
+
+// Push queues the transition. It never blocks.
+func (q *peerStateQueue) Push(transition PeerStateTransition) {
+	q.mu.Lock()
+	q.transitions = append(q.transitions, transition)
+	q.mu.Unlock()
+
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Process calls handle for each queued transition. It returns after the
+// transition to PeerStateClosed has been handled.
+func (q *peerStateQueue) Process(handle func(PeerStateTransition)) {
+	for range q.signal {
+		q.mu.Lock()
+		transitions := q.transitions
+		q.transitions = nil
+		q.mu.Unlock()
+
+		for _, transition := range transitions {
+			handle(transition)
+
+			if transition.To == PeerStateClosed {
+				return
+			}
+		}
+	}
+}
+
+func isValidPeerStateTransition(from PeerState, to PeerState) bool {
+	for _, state := range peerStateTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,109 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server"
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerStateMachine(t *testing.T) {
+	t.Parallel()
+
+	m := server.NewPeerStateMachine()
+
+	var transitions []string
+
+	m.OnTransition(func(transition server.PeerStateTransition) {
+		transitions = append(transitions, transition.From.String()+"->"+transition.To.String())
+	})
+
+	for _, iceState := range []webrtc.ICEConnectionState{
+		webrtc.ICEConnectionStateChecking,
+		webrtc.ICEConnectionStateConnected,
+		webrtc.ICEConnectionStateCompleted,
+		webrtc.ICEConnectionStateDisconnected,
+		webrtc.ICEConnectionStateChecking,
+		webrtc.ICEConnectionStateConnected,
+		webrtc.ICEConnectionStateDisconnected,
+		webrtc.ICEConnectionStateFailed,
+		webrtc.ICEConnectionStateClosed,
+	} {
+		m.HandleICEConnectionState(iceState)
+	}
+
+	assert.Equal(t, server.PeerStateClosed, m.State())
+
+	err := m.Transition(server.PeerStateClosed)
+	assert.True(t, multierr.Is(err, server.ErrInvalidPeerStateTransition))
+
+	err = m.Transition(server.PeerStateConnected)
+	assert.True(t, multierr.Is(err, server.ErrInvalidPeerStateTransition))
+
+	assert.Equal(t, []string{
+		"new->connecting",
+		"connecting->connected",
+		"connected->reconnecting",
+		"reconnecting->connected",
+		"connected->reconnecting",
+		"reconnecting->closed",
+	}, transitions)
+}
+
+func TestPeerStateWebhook(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan server.PeerStateWebhookEvent, 10)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event server.PeerStateWebhookEvent
+
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+
+		events <- event
+	}))
+	defer s.Close()
+
+	assert.Nil(t, server.NewPeerStateWebhook(test.NewLogger(), ""))
+
+	webhook := server.NewPeerStateWebhook(test.NewLogger(), s.URL)
+	require.NotNil(t, webhook)
+
+	defer webhook.Close()
+
+	states := []string{"new", "connecting", "connected", "reconnecting", "connected", "closed"}
+
+	var sent []server.PeerStateWebhookEvent
+
+	for i := 1; i < len(states); i++ {
+		event := server.PeerStateWebhookEvent{
+			RoomID:    "room1",
+			ClientID:  "client1",
+			From:      states[i-1],
+			To:        states[i],
+			Timestamp: time.Unix(int64(1000+i), 0).UTC(),
+		}
+
+		webhook.Send(event)
+
+		sent = append(sent, event)
+	}
+
+	// The events should be received in the same order they were sent.
+	for _, event := range sent {
+		select {
+		case received := <-events:
+			assert.Equal(t, event, received)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for webhook")
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/logger"
+)
+
+const (
+	peerStateWebhookTimeout = 5 * time.Second
+	// peerStateWebhookQueueSize is the number of events that can wait to be
+	// sent before new events are dropped.
+	peerStateWebhookQueueSize = 64
+)
+
+// PeerStateWebhookEvent is the JSON body posted to the peer state webhook.
+type PeerStateWebhookEvent struct {
+	RoomID    identifiers.RoomID   `json:"roomId"`
+	ClientID  identifiers.ClientID `json:"clientId"`
+	From      string               `json:"from"`
+	To        string               `json:"to"`
+	Timestamp time.Time            `json:"timestamp"`
+}
+
+// PeerStateWebhook posts peer state transitions to an external URL. Requests
+// are sent one at a time by a background worker so that the events arrive in
+// order. Failed requests are not retried.
+type PeerStateWebhook struct {
+	log    logger.Logger
+	url    string
+	client *http.Client
+
+	events    chan PeerStateWebhookEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPeerStateWebhook creates a new instance of PeerStateWebhook. It returns
+// nil when the url is empty.
+func NewPeerStateWebhook(log logger.Logger, url string) *PeerStateWebhook {
+	if url == "" {
+		return nil
+	}
+
+	w := &PeerStateWebhook{
+		log: log.WithNamespaceAppended("peer_state_webhook"),
+		url: url,
+		client: &http.Client{
+			Timeout: peerStateWebhookTimeout,
+		},
+		events: make(chan PeerStateWebhookEvent, peerStateWebhookQueueSize),
+		done:   make(chan struct{}),
+	}
+
+	go w.start()
+
+	return w
+}
+
+// Send queues the event to be posted. The event is dropped when the queue is
+// full.
+func (w *PeerStateWebhook) Send(event PeerStateWebhookEvent) {
+	select {
+	case w.events <- event:
+	default:
+		w.log.Warn("Peer state webhook queue is full, dropping event", logger.Ctx{
+			"room_id":   event.RoomID,
+			"client_id": event.ClientID,
+		})
+	}
+}
+
+// Close stops the background worker. Any queued events are discarded.
+func (w *PeerStateWebhook) Close() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+func (w *PeerStateWebhook) start() {
+	for {
+		select {
+		case event := <-w.events:
+			if err := w.send(event); err != nil {
+				w.log.Error("Send peer state webhook", errors.Trace(err), logger.Ctx{
+					"room_id":   event.RoomID,
+					"client_id": event.ClientID,
+				})
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *PeerStateWebhook) send(event PeerStateWebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	res, err := w.client.Post(w.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
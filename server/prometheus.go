@@ -62,6 +62,11 @@ var prometheusWebRTCConnDuration = promauto.NewHistogram(prometheus.HistogramOpt
 	Buckets: []float64{1, 60, 5 * 60, 15 * 60, 30 * 60, 45 * 60, 60 * 60, 120 * 60},
 })
 
+var prometheusWebRTCPeerStateTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webrtc_peer_state_transitions_total",
+	Help: "Total number of webrtc peer connection state transitions",
+}, []string{"from", "to"})
+
 var prometheusWebRTCTracksTotal = promauto.NewCounter(prometheus.CounterOpts{
 	Name: "webrtc_tracks_total",
 	Help: "Total number of incoming webrtc tracks",
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/atomic"
+	"github.com/peer-calls/peer-calls/v4/server/logger"
+)
+
+const (
+	defaultReplicationInterval        = time.Second
+	defaultReplicationFailoverTimeout = 5 * time.Second
+)
+
+var (
+	ErrNotStandby = errors.New("not a standby node")
+	ErrPromoted   = errors.New("standby node has been promoted")
+)
+
+type ReplicatorParams struct {
+	Log   logger.Logger
+	Rooms RoomSnapshotter
+	Role  ReplicationRole
+	// PeerURL is the base URL of the standby node. Only used by the active
+	// node.
+	PeerURL string
+	// AccessToken is used to authenticate against the admin API of the standby
+	// node.
+	AccessToken string
+	// HTTPClient is used to call the standby node. Defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// Interval between two snapshots sent to the standby node.
+	Interval time.Duration
+	// AutoFailover enables the promotion of the standby node when it stops
+	// receiving snapshots. Otherwise the standby node is only promoted through
+	// the admin API.
+	AutoFailover bool
+	// FailoverTimeout is the time after the last received snapshot after which
+	// the standby node promotes itself. Only used with AutoFailover.
+	FailoverTimeout time.Duration
+	// TTL defines for how long the clients can resume their sessions after
+	// the standby node has been promoted.
+	TTL time.Duration
+}
+
+// Replicator streams the client metadata (nicknames) of each room from the
+// active node to the standby node. Media sessions are not replicated, the
+// clients renegotiate them after reconnecting. The standby node keeps only
+// the latest snapshots and restores them when it is promoted, either by an
+// operator through the admin API, or when AutoFailover is enabled and the
+// active node stopped sending snapshots.
+//
+// The standby node reports itself as unhealthy until it is promoted, so that
+// a load balancer or a floating IP manager can move the advertised address to
+// it on failure. A promoted standby node rejects further snapshots, and the
+// active node steps down by reporting itself as unhealthy when that happens,
+// so that both nodes do not serve clients after a network partition heals.
+// The OnDemote handlers are then invoked to disconnect the local clients, so
+// that they reconnect to the promoted node.
+type Replicator struct {
+	params *ReplicatorParams
+
+	promoted atomic.Bool
+	// demoted is set on the active node after the standby node has been
+	// promoted.
+	demoted atomic.Bool
+
+	mu           sync.Mutex
+	snapshots    []RoomSnapshot
+	lastReceived time.Time
+	onDemote     []func()
+}
+
+// ReplicationStatus describes the state of the Replicator.
+type ReplicationStatus struct {
+	Role         ReplicationRole `json:"role"`
+	Promoted     bool            `json:"promoted"`
+	Demoted      bool            `json:"demoted,omitempty"`
+	NumRooms     int             `json:"numRooms"`
+	LastReceived *time.Time      `json:"lastReceived,omitempty"`
+}
+
+func NewReplicator(params ReplicatorParams) *Replicator {
+	params.Log = params.Log.WithNamespaceAppended("replicator").WithCtx(logger.Ctx{
+		"role": params.Role,
+	})
+
+	if params.HTTPClient == nil {
+		params.HTTPClient = http.DefaultClient
+	}
+
+	if params.Interval == 0 {
+		params.Interval = defaultReplicationInterval
+	}
+
+	if params.FailoverTimeout == 0 {
+		params.FailoverTimeout = defaultReplicationFailoverTimeout
+	}
+
+	if params.TTL == 0 {
+		params.TTL = defaultSnapshotTTL
+	}
+
+	return &Replicator{
+		params: &params,
+	}
+}
+
+// Healthy returns false for standby nodes that have not been promoted, and
+// for active nodes that have stepped down.
+func (r *Replicator) Healthy() bool {
+	if r.params.Role == ReplicationRoleStandby {
+		return r.promoted.Get()
+	}
+
+	return !r.demoted.Get()
+}
+
+// OnDemote registers a handler that is invoked once, after the active node
+// has stepped down.
+func (r *Replicator) OnDemote(handler func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onDemote = append(r.onDemote, handler)
+}
+
+// Status returns the current state of replication.
+func (r *Replicator) Status() ReplicationStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := ReplicationStatus{
+		Role:     r.params.Role,
+		Promoted: r.promoted.Get(),
+		Demoted:  r.demoted.Get(),
+		NumRooms: len(r.snapshots),
+	}
+
+	if !r.lastReceived.IsZero() {
+		lastReceived := r.lastReceived
+		status.LastReceived = &lastReceived
+	}
+
+	return status
+}
+
+// Receive is called on the standby node with the latest snapshots of the
+// active node. It returns ErrPromoted after the standby node has been
+// promoted.
+func (r *Replicator) Receive(snapshots []RoomSnapshot) error {
+	if r.params.Role != ReplicationRoleStandby {
+		return errors.Trace(ErrNotStandby)
+	}
+
+	if r.promoted.Get() {
+		return errors.Trace(ErrPromoted)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.snapshots = snapshots
+	r.lastReceived = time.Now()
+
+	return nil
+}
+
+// Promote restores the last received snapshots and marks the standby node as
+// healthy. It returns false when the node has already been promoted.
+func (r *Replicator) Promote() (bool, error) {
+	if r.params.Role != ReplicationRoleStandby {
+		return false, errors.Trace(ErrNotStandby)
+	}
+
+	if !r.promoted.CompareAndSwap(true) {
+		return false, nil
+	}
+
+	r.mu.Lock()
+
+	snapshots := r.snapshots
+	r.snapshots = nil
+
+	r.mu.Unlock()
+
+	// The clients will start reconnecting only after the failover, so the TTL
+	// should start now.
+	now := time.Now()
+
+	for i := range snapshots {
+		snapshots[i].Timestamp = now
+	}
+
+	r.params.Log.Info("Promoted to active", logger.Ctx{
+		"num_rooms": len(snapshots),
+	})
+
+	r.params.Rooms.Restore(snapshots, r.params.TTL)
+
+	return true, nil
+}
+
+// Run sends snapshots to the standby node on the active node, or watches for
+// failures of the active node on the standby node, until the context is
+// canceled.
+func (r *Replicator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.params.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.tick(ctx, time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *Replicator) tick(ctx context.Context, now time.Time) {
+	switch r.params.Role {
+	case ReplicationRoleActive:
+		if r.demoted.Get() {
+			return
+		}
+
+		if err := r.send(ctx); err != nil {
+			r.params.Log.Error("Send snapshots to standby", errors.Trace(err), nil)
+		}
+	case ReplicationRoleStandby:
+		if r.params.AutoFailover && r.activeFailed(now) {
+			r.params.Log.Warn("Active node stopped sending snapshots, promoting", nil)
+
+			if _, err := r.Promote(); err != nil {
+				r.params.Log.Error("Promote", errors.Trace(err), nil)
+			}
+		}
+	}
+}
+
+// activeFailed returns true when the active node has sent at least one
+// snapshot, but has not sent any since the failover timeout.
+func (r *Replicator) activeFailed(now time.Time) bool {
+	if r.promoted.Get() {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return !r.lastReceived.IsZero() && now.Sub(r.lastReceived) > r.params.FailoverTimeout
+}
+
+func (r *Replicator) send(ctx context.Context) error {
+	b, err := json.Marshal(r.params.Rooms.Snapshot())
+	if err != nil {
+		return errors.Annotate(err, "marshal snapshots")
+	}
+
+	snapshotsURL := strings.TrimSuffix(r.params.PeerURL, "/") + "/api/replication/snapshots"
+
+	ctx, cancel := context.WithTimeout(ctx, r.params.Interval)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, snapshotsURL, bytes.NewReader(b))
+	if err != nil {
+		return errors.Annotate(err, "new request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+r.params.AccessToken)
+
+	res, err := r.params.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Annotatef(err, "put %s", snapshotsURL)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		// The standby node has been promoted, so it is serving the clients now.
+		r.demote()
+
+		return nil
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("put %s: unexpected status code: %d", snapshotsURL, res.StatusCode)
+	}
+
+	return nil
+}
+
+func (r *Replicator) demote() {
+	if !r.demoted.CompareAndSwap(true) {
+		return
+	}
+
+	r.params.Log.Warn("Standby node has been promoted, stepping down", nil)
+
+	r.mu.Lock()
+	handlers := r.onDemote
+	r.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler()
+	}
+}
@@ -0,0 +1,328 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server"
+	"github.com/peer-calls/peer-calls/v4/server/message"
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+)
+
+func TestReplicator_failover(t *testing.T) {
+	t.Parallel()
+
+	activeRooms := newMemoryRoomManager()
+
+	adapter, _ := activeRooms.Enter("room1")
+
+	client := server.NewClientWithID(NewMockWriter(), "a")
+	defer client.Close(websocket.StatusNormalClosure, "")
+
+	client.SetMetadata("nick-a")
+	require.NoError(t, adapter.Add(client))
+
+	standbyRooms := newMemoryRoomManager()
+
+	standby := server.NewReplicator(server.ReplicatorParams{
+		Log:             test.NewLogger(),
+		Rooms:           standbyRooms,
+		Role:            server.ReplicationRoleStandby,
+		Interval:        10 * time.Millisecond,
+		AutoFailover:    true,
+		FailoverTimeout: 100 * time.Millisecond,
+		TTL:             time.Minute,
+	})
+
+	api := server.NewAPIHandler(server.APIHandlerParams{
+		Log:         test.NewLogger(),
+		AccessToken: adminAccessToken,
+		Replicator:  standby,
+	})
+
+	s := httptest.NewServer(http.StripPrefix("/api", api))
+	defer s.Close()
+
+	active := server.NewReplicator(server.ReplicatorParams{
+		Log:         test.NewLogger(),
+		Rooms:       activeRooms,
+		Role:        server.ReplicationRoleActive,
+		PeerURL:     s.URL,
+		AccessToken: adminAccessToken,
+		Interval:    10 * time.Millisecond,
+	})
+
+	assert.True(t, active.Healthy())
+	assert.False(t, standby.Healthy())
+
+	activeCtx, activeCancel := context.WithCancel(context.Background())
+	defer activeCancel()
+
+	standbyCtx, standbyCancel := context.WithCancel(context.Background())
+	defer standbyCancel()
+
+	activeDone := make(chan struct{})
+
+	go func() {
+		defer close(activeDone)
+
+		active.Run(activeCtx)
+	}()
+
+	go standby.Run(standbyCtx)
+
+	require.Eventually(t, func() bool {
+		return standby.Status().NumRooms == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.False(t, standby.Healthy(), "standby should not be promoted while the active node is alive")
+
+	// Simulate a crash of the active node.
+	activeCancel()
+	<-activeDone
+
+	require.Eventually(t, standby.Healthy, time.Second, 10*time.Millisecond)
+
+	status := standby.Status()
+	assert.True(t, status.Promoted)
+	assert.NotNil(t, status.LastReceived)
+
+	metadata, ok := standbyRooms.RestoredMetadata("room1", "a", "")
+	assert.True(t, ok)
+	assert.Equal(t, "nick-a", metadata)
+
+	promoted, err := standby.Promote()
+	assert.NoError(t, err)
+	assert.False(t, promoted, "should only be promoted once")
+}
+
+func TestReplicator_noAutoFailover(t *testing.T) {
+	t.Parallel()
+
+	standby := server.NewReplicator(server.ReplicatorParams{
+		Log:             test.NewLogger(),
+		Rooms:           newMemoryRoomManager(),
+		Role:            server.ReplicationRoleStandby,
+		Interval:        10 * time.Millisecond,
+		FailoverTimeout: 20 * time.Millisecond,
+	})
+
+	require.NoError(t, standby.Receive(nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go standby.Run(ctx)
+
+	assert.Never(t, standby.Healthy, 200*time.Millisecond, 10*time.Millisecond,
+		"standby should only be promoted through the API")
+}
+
+func TestReplicator_stepDown(t *testing.T) {
+	t.Parallel()
+
+	standby := server.NewReplicator(server.ReplicatorParams{
+		Log:   test.NewLogger(),
+		Rooms: newMemoryRoomManager(),
+		Role:  server.ReplicationRoleStandby,
+	})
+
+	api := server.NewAPIHandler(server.APIHandlerParams{
+		Log:         test.NewLogger(),
+		AccessToken: adminAccessToken,
+		Replicator:  standby,
+	})
+
+	s := httptest.NewServer(http.StripPrefix("/api", api))
+	defer s.Close()
+
+	active := server.NewReplicator(server.ReplicatorParams{
+		Log:         test.NewLogger(),
+		Rooms:       newMemoryRoomManager(),
+		Role:        server.ReplicationRoleActive,
+		PeerURL:     s.URL,
+		AccessToken: adminAccessToken,
+		Interval:    10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go active.Run(ctx)
+
+	require.Eventually(t, func() bool {
+		return standby.Status().LastReceived != nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.True(t, active.Healthy())
+
+	// Promote the standby node, for example after a network partition.
+	promoted, err := standby.Promote()
+	require.NoError(t, err)
+	assert.True(t, promoted)
+
+	err = standby.Receive(nil)
+	assert.True(t, multierr.Is(err, server.ErrPromoted), "expected ErrPromoted, but got: %+v", err)
+
+	require.Eventually(t, func() bool {
+		return !active.Healthy()
+	}, time.Second, 10*time.Millisecond, "active node should step down")
+
+	assert.True(t, active.Status().Demoted)
+	assert.True(t, standby.Healthy())
+}
+
+func TestReplicator_notStandby(t *testing.T) {
+	t.Parallel()
+
+	active := server.NewReplicator(server.ReplicatorParams{
+		Log:   test.NewLogger(),
+		Rooms: newMemoryRoomManager(),
+		Role:  server.ReplicationRoleActive,
+	})
+
+	err := active.Receive(nil)
+	assert.True(t, multierr.Is(err, server.ErrNotStandby), "expected ErrNotStandby, but got: %+v", err)
+
+	_, err = active.Promote()
+	assert.True(t, multierr.Is(err, server.ErrNotStandby), "expected ErrNotStandby, but got: %+v", err)
+}
+
+func TestAPIHandler_replication(t *testing.T) {
+	standbyRooms := newMemoryRoomManager()
+
+	standby := server.NewReplicator(server.ReplicatorParams{
+		Log:   test.NewLogger(),
+		Rooms: standbyRooms,
+		Role:  server.ReplicationRoleStandby,
+		TTL:   time.Minute,
+	})
+
+	s := newAPIServer(t, server.APIHandlerParams{
+		Replicator: standby,
+	})
+
+	statusCode, body := doAPIRequest(t, http.MethodGet, s.URL+"/replication", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, `{"role":"standby","promoted":false,"numRooms":0}`, body)
+
+	statusCode, _ = doAPIRequest(t, http.MethodPut, s.URL+"/replication/snapshots", "invalid")
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+
+	statusCode, _ = doAPIRequest(t, http.MethodPut, s.URL+"/replication/snapshots",
+		`[{"roomId":"room1","clients":{"a":"nick-a"},"timestamp":"2020-01-01T00:00:00Z"}]`)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, 1, standby.Status().NumRooms)
+
+	statusCode, _ = doAPIRequest(t, http.MethodPost, s.URL+"/replication/promote", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.True(t, standby.Healthy())
+
+	// The snapshot timestamp is reset on promotion so the old timestamp above
+	// should not have expired.
+	metadata, ok := standbyRooms.RestoredMetadata("room1", "a", "")
+	assert.True(t, ok)
+	assert.Equal(t, "nick-a", metadata)
+
+	// A promoted standby node tells the active node to step down.
+	statusCode, _ = doAPIRequest(t, http.MethodPut, s.URL+"/replication/snapshots", "[]")
+	assert.Equal(t, http.StatusConflict, statusCode)
+
+	active := server.NewReplicator(server.ReplicatorParams{
+		Log:   test.NewLogger(),
+		Rooms: newMemoryRoomManager(),
+		Role:  server.ReplicationRoleActive,
+	})
+
+	s = newAPIServer(t, server.APIHandlerParams{
+		Replicator: active,
+	})
+
+	statusCode, _ = doAPIRequest(t, http.MethodPut, s.URL+"/replication/snapshots", "[]")
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+}
+
+func TestReplicator_stepDown_disconnectClients(t *testing.T) {
+	standby := server.NewReplicator(server.ReplicatorParams{
+		Log:   test.NewLogger(),
+		Rooms: newMemoryRoomManager(),
+		Role:  server.ReplicationRoleStandby,
+	})
+
+	_, err := standby.Promote()
+	require.NoError(t, err)
+
+	api := server.NewAPIHandler(server.APIHandlerParams{
+		Log:         test.NewLogger(),
+		AccessToken: adminAccessToken,
+		Replicator:  standby,
+	})
+
+	standbyServer := httptest.NewServer(http.StripPrefix("/api", api))
+	defer standbyServer.Close()
+
+	rooms := newMemoryRoomManager()
+
+	active := server.NewReplicator(server.ReplicatorParams{
+		Log:         test.NewLogger(),
+		Rooms:       rooms,
+		Role:        server.ReplicationRoleActive,
+		PeerURL:     standbyServer.URL,
+		AccessToken: adminAccessToken,
+		Interval:    10 * time.Millisecond,
+	})
+
+	trk := newMockTracksManager()
+
+	mux := server.NewMux(test.NewLogger(), "", "v0.0.0", mesh(), iceServers, false, nil, rooms, trk, nil, active, prom(), embed, nil)
+
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(s.URL, "http") + "/ws/room1/a"
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ws := mustDialWS(t, ctx, wsURL)
+	defer ws.Close(websocket.StatusNormalClosure, "")
+
+	mustWriteWS(t, ctx, ws, message.NewReady("room1", message.Ready{
+		Nickname: "nick-a",
+	}))
+	mustReadWSType(t, ctx, ws, message.TypeUsers)
+
+	runCtx, runCancel := context.WithCancel(context.Background())
+	defer runCancel()
+
+	go active.Run(runCtx)
+
+	// Skip any remaining messages until the connection is closed.
+	for {
+		_, _, err = ws.Read(ctx)
+		if err != nil {
+			break
+		}
+	}
+
+	assert.Equal(t, websocket.StatusServiceRestart, websocket.CloseStatus(err),
+		"clients should be disconnected after stepping down, got: %+v", err)
+	assert.True(t, active.Status().Demoted)
+
+	_, res, err := websocket.Dial(ctx, wsURL, nil)
+	require.Error(t, err, "should not accept clients after stepping down")
+	require.NotNil(t, res)
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	require.Eventually(t, func() bool {
+		return len(rooms.Snapshot()) == 0
+	}, time.Second, 10*time.Millisecond, "client should have left the room")
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"io"
 	"sync"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/peer-calls/peer-calls/v4/server/identifiers"
@@ -19,14 +20,30 @@ type AdapterRoomManager struct {
 	rooms      map[identifiers.RoomID]*adapterCounter
 	roomsMu    sync.RWMutex
 	newAdapter NewAdapterFunc
+
+	// restored contains the snapshots of rooms restored after a restart or a
+	// migration. A client is removed from the snapshot once it reconnects.
+	restored map[identifiers.RoomID]restoredRoom
+	// draining contains the target URLs of rooms being migrated away.
+	draining map[identifiers.RoomID]string
+}
+
+type restoredRoom struct {
+	snapshot RoomSnapshot
+	ttl      time.Duration
 }
 
-var _ RoomManager = &AdapterRoomManager{}
+var (
+	_ RoomManager           = &AdapterRoomManager{}
+	_ MigratableRoomManager = &AdapterRoomManager{}
+)
 
 func NewAdapterRoomManager(newAdapter NewAdapterFunc) *AdapterRoomManager {
 	return &AdapterRoomManager{
 		rooms:      map[identifiers.RoomID]*adapterCounter{},
 		newAdapter: newAdapter,
+		restored:   map[identifiers.RoomID]restoredRoom{},
+		draining:   map[identifiers.RoomID]string{},
 	}
 }
 
@@ -67,6 +84,172 @@ func (r *AdapterRoomManager) Exit(room identifiers.RoomID) (isRemoved bool) {
 	return isRemoved
 }
 
+// Snapshot returns the current state of all rooms. Restored rooms that have
+// not yet expired are included so that the state survives a second restart
+// even if the clients have not reconnected in the meantime.
+func (r *AdapterRoomManager) Snapshot() []RoomSnapshot {
+	now := time.Now()
+
+	// Copy everything under the read lock and query the adapters afterwards,
+	// since each Clients call might be a round-trip to redis.
+	r.roomsMu.RLock()
+
+	adapters := make(map[identifiers.RoomID]Adapter, len(r.rooms))
+	for room, ac := range r.rooms {
+		adapters[room] = ac.adapter
+	}
+
+	restoredSnapshots := make(map[identifiers.RoomID]RoomSnapshot, len(r.restored))
+
+	for room, restored := range r.restored {
+		if restored.snapshot.Expired(now, restored.ttl) {
+			continue
+		}
+
+		snapshot := restored.snapshot
+		snapshot.Clients = make(map[identifiers.ClientID]string, len(restored.snapshot.Clients))
+
+		for clientID, metadata := range restored.snapshot.Clients {
+			snapshot.Clients[clientID] = metadata
+		}
+
+		restoredSnapshots[room] = snapshot
+	}
+
+	r.roomsMu.RUnlock()
+
+	snapshots := make([]RoomSnapshot, 0, len(adapters)+len(restoredSnapshots))
+
+	for room, adapter := range adapters {
+		clients, err := adapter.Clients()
+		if err != nil {
+			// The adapter might be temporarily unavailable or already closed.
+			// Skip the room and try again during the next snapshot.
+			continue
+		}
+
+		snapshot := RoomSnapshot{
+			RoomID:    room,
+			Clients:   clients,
+			Timestamp: now,
+		}
+
+		if restored, ok := restoredSnapshots[room]; ok {
+			for clientID, metadata := range restored.Clients {
+				if _, ok := snapshot.Clients[clientID]; !ok {
+					snapshot.Clients[clientID] = metadata
+				}
+			}
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	for room, restored := range restoredSnapshots {
+		if _, ok := adapters[room]; !ok {
+			snapshots = append(snapshots, restored)
+		}
+	}
+
+	return snapshots
+}
+
+// removeExpired removes expired restored snapshots. The caller must hold the
+// lock.
+func (r *AdapterRoomManager) removeExpired(now time.Time) {
+	for room, restored := range r.restored {
+		if restored.snapshot.Expired(now, restored.ttl) {
+			delete(r.restored, room)
+		}
+	}
+}
+
+// Restore stores the snapshots so that the clients can be restored once they
+// reconnect. Snapshots older than ttl are ignored.
+func (r *AdapterRoomManager) Restore(snapshots []RoomSnapshot, ttl time.Duration) {
+	r.roomsMu.Lock()
+	defer r.roomsMu.Unlock()
+
+	for _, snapshot := range snapshots {
+		clients := make(map[identifiers.ClientID]string, len(snapshot.Clients))
+
+		for clientID, metadata := range snapshot.Clients {
+			clients[clientID] = metadata
+		}
+
+		snapshot.Clients = clients
+
+		r.restored[snapshot.RoomID] = restoredRoom{
+			snapshot: snapshot,
+			ttl:      ttl,
+		}
+	}
+}
+
+// RestoredMetadata returns the metadata a client had before the restart.
+// The metadata can only be retrieved once. When the snapshot contains a
+// resume token for the client, the provided resumeToken must match it.
+func (r *AdapterRoomManager) RestoredMetadata(
+	room identifiers.RoomID,
+	clientID identifiers.ClientID,
+	resumeToken string,
+) (metadata string, ok bool) {
+	r.roomsMu.Lock()
+	defer r.roomsMu.Unlock()
+
+	r.removeExpired(time.Now())
+
+	restored, ok := r.restored[room]
+	if !ok {
+		return "", false
+	}
+
+	snapshot := restored.snapshot
+
+	metadata, ok = snapshot.Clients[clientID]
+	if !ok {
+		return "", false
+	}
+
+	if token, ok := snapshot.ResumeTokens[clientID]; ok && token != resumeToken {
+		return "", false
+	}
+
+	delete(snapshot.Clients, clientID)
+
+	if len(snapshot.Clients) == 0 {
+		delete(r.restored, room)
+	}
+
+	return metadata, true
+}
+
+// SetDraining marks the room as being migrated to the node at targetURL.
+// Clients joining a draining room will be asked to reconnect to targetURL.
+// An empty targetURL removes the mark.
+func (r *AdapterRoomManager) SetDraining(room identifiers.RoomID, targetURL string) {
+	r.roomsMu.Lock()
+	defer r.roomsMu.Unlock()
+
+	if targetURL == "" {
+		delete(r.draining, room)
+
+		return
+	}
+
+	r.draining[room] = targetURL
+}
+
+// Draining returns the target URL when the room is being drained.
+func (r *AdapterRoomManager) Draining(room identifiers.RoomID) (targetURL string, ok bool) {
+	r.roomsMu.RLock()
+	defer r.roomsMu.RUnlock()
+
+	targetURL, ok = r.draining[room]
+
+	return targetURL, ok
+}
+
 type ChannelRoomManager struct {
 	roomManager         RoomManager
 	roomEventsChan      chan RoomEvent
@@ -130,6 +313,52 @@ func (r *ChannelRoomManager) Exit(room identifiers.RoomID) (isRemoved bool) {
 	return isRemoved
 }
 
+// Snapshot implements RoomSnapshotter. It returns nil when the underlying
+// RoomManager does not implement RoomSnapshotter.
+func (r *ChannelRoomManager) Snapshot() []RoomSnapshot {
+	if snapshotter, ok := r.roomManager.(RoomSnapshotter); ok {
+		return snapshotter.Snapshot()
+	}
+
+	return nil
+}
+
+// Restore implements RoomSnapshotter.
+func (r *ChannelRoomManager) Restore(snapshots []RoomSnapshot, ttl time.Duration) {
+	if snapshotter, ok := r.roomManager.(RoomSnapshotter); ok {
+		snapshotter.Restore(snapshots, ttl)
+	}
+}
+
+// RestoredMetadata implements metadataRestorer.
+func (r *ChannelRoomManager) RestoredMetadata(
+	room identifiers.RoomID,
+	clientID identifiers.ClientID,
+	resumeToken string,
+) (string, bool) {
+	if restorer, ok := r.roomManager.(metadataRestorer); ok {
+		return restorer.RestoredMetadata(room, clientID, resumeToken)
+	}
+
+	return "", false
+}
+
+// SetDraining implements roomDrainer.
+func (r *ChannelRoomManager) SetDraining(room identifiers.RoomID, targetURL string) {
+	if drainer, ok := r.roomManager.(roomDrainer); ok {
+		drainer.SetDraining(room, targetURL)
+	}
+}
+
+// Draining implements roomDrainer.
+func (r *ChannelRoomManager) Draining(room identifiers.RoomID) (string, bool) {
+	if drainer, ok := r.roomManager.(roomDrainer); ok {
+		return drainer.Draining(room)
+	}
+
+	return "", false
+}
+
 func (r *ChannelRoomManager) AcceptEvent() (RoomEvent, error) {
 	event, ok := <-r.roomEventsChan
 	if !ok {
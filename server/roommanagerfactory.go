@@ -60,6 +60,7 @@ func (rmf *RoomManagerFactory) createChannelRoomManager(
 		Nodes:         nodes,
 		RoomManager:   channelRoomManager,
 		TracksManager: rmf.params.TracksManager,
+		ReceiveMTU:    c.SFU.Transport.ReceiveMTU,
 	})
 	if err != nil {
 		channelRoomManager.Close()
@@ -21,11 +21,17 @@ var _ transport.DataTransport = &DataTransport{}
 type DataTransportParams struct {
 	Log  logger.Logger
 	Conn io.ReadWriteCloser
+	// ReceiveMTU is optional. DefaultReceiveMTU will be used when zero.
+	ReceiveMTU int
 }
 
 func NewDataTransport(params DataTransportParams) *DataTransport {
 	params.Log = params.Log.WithNamespaceAppended("server_data_transport")
 
+	if params.ReceiveMTU == 0 {
+		params.ReceiveMTU = DefaultReceiveMTU
+	}
+
 	transport := &DataTransport{
 		params:       params,
 		messagesChan: make(chan webrtc.DataChannelMessage),
@@ -39,7 +45,10 @@ func NewDataTransport(params DataTransportParams) *DataTransport {
 func (t *DataTransport) start() {
 	defer close(t.messagesChan)
 
-	buf := make([]byte, ReceiveMTU)
+	// The extra byte is used to detect packets larger than ReceiveMTU.
+	buf := make([]byte, t.params.ReceiveMTU+1)
+
+	oversized := newOversizedPacketLogger(t.params.Log, packetTypeData, t.params.ReceiveMTU)
 
 	for {
 		i, err := t.params.Conn.Read(buf)
@@ -49,6 +58,12 @@ func (t *DataTransport) start() {
 			return
 		}
 
+		if i > t.params.ReceiveMTU {
+			oversized.Drop()
+
+			continue
+		}
+
 		if i < 1 {
 			t.params.Log.Error(fmt.Sprintf("Message too short: %d", i), nil, nil)
 
@@ -60,9 +75,12 @@ func (t *DataTransport) start() {
 		isString := !(buf[0] == 0)
 
 		// TODO figure out which user a message belongs to.
+		data := make([]byte, i-1)
+		copy(data, buf[1:i])
+
 		message := webrtc.DataChannelMessage{
 			IsString: isString,
-			Data:     buf[1:],
+			Data:     data,
 		}
 
 		t.messagesChan <- message
@@ -0,0 +1,48 @@
+package servertransport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataTransport_receiveMTU(t *testing.T) {
+	conn1, conn2 := newUDPPair(5)
+
+	defer conn1.Close()
+	defer conn2.Close()
+
+	const receiveMTU = 20
+
+	dataTransport := NewDataTransport(DataTransportParams{
+		Log:        test.NewLogger(),
+		Conn:       conn1,
+		ReceiveMTU: receiveMTU,
+	})
+
+	// The first byte marks the message as a string.
+	oversized := append([]byte{1}, make([]byte, receiveMTU)...)
+
+	_, err := conn2.Write(oversized)
+	require.NoError(t, err)
+
+	exact := append([]byte{1}, []byte("0123456789012345678")...)
+	require.Len(t, exact, receiveMTU)
+
+	_, err = conn2.Write(exact)
+	require.NoError(t, err)
+
+	select {
+	case msg := <-dataTransport.MessagesChannel():
+		assert.Equal(t, webrtc.DataChannelMessage{
+			IsString: true,
+			Data:     exact[1:],
+		}, msg, "messages of exactly ReceiveMTU size should not be dropped")
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for message")
+	}
+}
@@ -37,6 +37,7 @@ type MediaStream struct {
 		readRTPPackets  int64
 		readRTCPPackets int64
 		readUnknown     int64
+		readOversized   int64
 
 		sentBytes       int64
 		sentRTPPackets  int64
@@ -65,6 +66,8 @@ type MediaStreamParams struct {
 	BufferFactory BufferFactory
 	// Interceptor is optional.
 	Interceptor interceptor.Interceptor
+	// ReceiveMTU is optional. DefaultReceiveMTU will be used when zero.
+	ReceiveMTU int
 }
 
 func NewMediaStream(params MediaStreamParams) *MediaStream {
@@ -72,6 +75,10 @@ func NewMediaStream(params MediaStreamParams) *MediaStream {
 		params.BufferFactory = newBuffer
 	}
 
+	if params.ReceiveMTU == 0 {
+		params.ReceiveMTU = DefaultReceiveMTU
+	}
+
 	params.Log = params.Log.WithNamespaceAppended("server_media_transport")
 
 	t := MediaStream{
@@ -89,7 +96,11 @@ func NewMediaStream(params MediaStreamParams) *MediaStream {
 }
 
 func (t *MediaStream) start() {
-	buf := make([]byte, ReceiveMTU)
+	// The extra byte is used to detect packets larger than ReceiveMTU, since
+	// these are truncated to the size of the buffer.
+	buf := make([]byte, t.params.ReceiveMTU+1)
+
+	oversized := newOversizedPacketLogger(t.params.Log, packetTypeMedia, t.params.ReceiveMTU)
 
 	for {
 		i, err := t.params.Conn.Read(buf)
@@ -101,6 +112,15 @@ func (t *MediaStream) start() {
 
 		atomic.AddInt64(&t.stats.readBytes, int64(i))
 
+		if i > t.params.ReceiveMTU {
+			// The packet has most likely been truncated, so there is no point in
+			// handling it.
+			atomic.AddInt64(&t.stats.readOversized, 1)
+			oversized.Drop()
+
+			continue
+		}
+
 		// Bytes need to be copied from the buffer because unmarshaling RTP and
 		// RTCP packets will not create copies, so the raw body of these packets
 		// such as RTP.Payload would be replaced before being marshaled and sent
@@ -208,7 +228,7 @@ func (t *MediaStream) removeRTCPBuffer(ssrc webrtc.SSRC) {
 	b, ok := t.rtcpBuffers[ssrc]
 	if ok {
 		b.Close()
-		delete(t.rtpBuffers, ssrc)
+		delete(t.rtcpBuffers, ssrc)
 	}
 
 	t.mu.Unlock()
@@ -220,7 +240,7 @@ func (t *MediaStream) removeRTPBuffer(ssrc webrtc.SSRC) {
 	b, ok := t.rtpBuffers[ssrc]
 	if ok {
 		b.Close()
-		delete(t.rtcpBuffers, ssrc)
+		delete(t.rtpBuffers, ssrc)
 	}
 
 	t.mu.Unlock()
@@ -0,0 +1,69 @@
+package servertransport
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/transport/packetio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaStream_receiveMTU(t *testing.T) {
+	conn1, conn2 := newUDPPair(4)
+
+	defer conn1.Close()
+	defer conn2.Close()
+
+	const receiveMTU = 20
+
+	mediaStream := NewMediaStream(MediaStreamParams{
+		Log:         test.NewLogger(),
+		Conn:        conn1,
+		Interceptor: &interceptor.NoOp{},
+		ReceiveMTU:  receiveMTU,
+	})
+
+	buffer := mediaStream.GetOrCreateBuffer(packetio.RTPBufferPacket, 1)
+
+	newPacket := func(size int) []byte {
+		packet := rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    96,
+				SequenceNumber: uint16(size),
+				SSRC:           1,
+			},
+		}
+
+		packet.Payload = make([]byte, size-packet.Header.MarshalSize())
+
+		b, err := packet.Marshal()
+		require.NoError(t, err)
+		require.Len(t, b, size)
+
+		return b
+	}
+
+	_, err := conn2.Write(newPacket(receiveMTU + 1))
+	require.NoError(t, err)
+
+	exact := newPacket(receiveMTU)
+
+	_, err = conn2.Write(exact)
+	require.NoError(t, err)
+
+	require.NoError(t, buffer.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	b := make([]byte, receiveMTU)
+
+	i, err := buffer.Read(b)
+	require.NoError(t, err)
+	assert.Equal(t, exact, b[:i], "packets of exactly ReceiveMTU size should not be dropped")
+
+	assert.Equal(t, int64(1), atomic.LoadInt64(&mediaStream.stats.readOversized))
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/peer-calls/peer-calls/v4/server/transport"
 	"github.com/pion/interceptor"
 	"github.com/pion/randutil"
+	"github.com/pion/rtcp"
 	"github.com/pion/transport/packetio"
 	"github.com/pion/webrtc/v3"
 )
@@ -48,6 +49,8 @@ type MetadataTransportParams struct {
 	ClientID      identifiers.ClientID
 	Interceptor   interceptor.Interceptor
 	CodecRegistry *codecs.Registry
+	// ReceiveMTU is optional. DefaultReceiveMTU will be used when zero.
+	ReceiveMTU int
 }
 
 type trackLocalWithRTCPReader struct {
@@ -63,6 +66,10 @@ type trackRemoteWithRTCPReader struct {
 func NewMetadataTransport(params MetadataTransportParams) *MetadataTransport {
 	params.Log = params.Log.WithNamespaceAppended("metadata_transport")
 
+	if params.ReceiveMTU == 0 {
+		params.ReceiveMTU = DefaultReceiveMTU
+	}
+
 	t := &MetadataTransport{
 		params: params,
 
@@ -132,7 +139,10 @@ func (t *MetadataTransport) startReadLoop() {
 		t.params.Log.Trace("Read closed", nil)
 	}()
 
-	buf := make([]byte, ReceiveMTU)
+	// The extra byte is used to detect packets larger than ReceiveMTU.
+	buf := make([]byte, t.params.ReceiveMTU+1)
+
+	oversized := newOversizedPacketLogger(t.params.Log, packetTypeMetadata, t.params.ReceiveMTU)
 
 	for {
 		i, err := t.params.Conn.Read(buf)
@@ -142,6 +152,12 @@ func (t *MetadataTransport) startReadLoop() {
 			return
 		}
 
+		if i > t.params.ReceiveMTU {
+			oversized.Drop()
+
+			continue
+		}
+
 		var event metadataEvent
 
 		err = json.Unmarshal(buf[:i], &event)
@@ -220,6 +236,7 @@ func (t *MetadataTransport) startReadLoop() {
 							track.Codec(),
 							t.params.Interceptor,
 							interceptorParams,
+							t.params.ReceiveMTU,
 							subscribe,
 							unsubscribe,
 						)
@@ -227,6 +244,7 @@ func (t *MetadataTransport) startReadLoop() {
 						rtcpReader = newRTCPReader(
 							t.params.MediaStream.GetOrCreateBuffer(packetio.RTCPBufferPacket, trackEv.SSRC),
 							t.params.Interceptor,
+							t.params.ReceiveMTU,
 						)
 
 						t.remoteTracks[trackID] = &trackRemoteWithRTCPReader{
@@ -334,7 +352,7 @@ func (t *MetadataTransport) AddTrack(track transport.Track) (transport.TrackLoca
 	)
 
 	rtcpBuffer := t.params.MediaStream.GetOrCreateBuffer(packetio.RTCPBufferPacket, ssrc)
-	sender := newRTCPReader(rtcpBuffer, t.params.Interceptor)
+	sender := newRTCPReader(rtcpBuffer, t.params.Interceptor, t.params.ReceiveMTU)
 
 	t.localTracks[track.TrackID()] = &trackLocalWithRTCPReader{
 		trackLocal: localTrack,
@@ -383,10 +401,24 @@ func (t *MetadataTransport) RemoveTrack(trackID identifiers.TrackID) error {
 		return errors.Errorf("remove track: not found: %s", trackID)
 	}
 
+	ssrc := ltwr.trackLocal.ssrc()
+
+	// Let the subscriber know the stream has ended before the track is torn
+	// down, the same way WebRTCTransport does.
+	if err := t.params.MediaStream.WriteRTCP([]rtcp.Packet{
+		&rtcp.Goodbye{
+			Sources: []uint32{uint32(ssrc)},
+		},
+	}); err != nil {
+		t.params.Log.Warn("Send RTCP BYE", logger.Ctx{
+			"track_id": trackID,
+			"error":    err.Error(),
+		})
+	}
+
 	// Ensure writing stops and interceptors are released.
 	ltwr.rtcpReader.Close()
 	ltwr.trackLocal.Close()
-	ssrc := ltwr.trackLocal.ssrc()
 
 	// Ensure the RTCP buffer is closed. This will close the sender.
 	t.params.MediaStream.RemoveBuffer(packetio.RTCPBufferPacket, ssrc)
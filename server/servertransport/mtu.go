@@ -0,0 +1,81 @@
+package servertransport
+
+import (
+	"io"
+
+	"github.com/peer-calls/peer-calls/v4/server/logger"
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/pion/interceptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	packetTypeMedia    = "media"
+	packetTypeData     = "data"
+	packetTypeMetadata = "metadata"
+	packetTypeRTP      = "rtp"
+	packetTypeRTCP     = "rtcp"
+)
+
+var prometheusOversizedPackets = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "server_transport_oversized_packets_total",
+	Help: "Total number of dropped packets larger than the receive MTU",
+}, []string{"type"})
+
+func observeOversizedPacket(packetType string) {
+	prometheusOversizedPackets.WithLabelValues(packetType).Inc()
+}
+
+// oversizedPacketLogger counts the oversized packets dropped from a single
+// connection. Only the first one is logged so that a peer with a larger MTU
+// does not flood the log, the rest can be found in the metrics. It is not
+// safe for concurrent use.
+type oversizedPacketLogger struct {
+	log        logger.Logger
+	packetType string
+	receiveMTU int
+
+	logged bool
+}
+
+func newOversizedPacketLogger(log logger.Logger, packetType string, receiveMTU int) *oversizedPacketLogger {
+	return &oversizedPacketLogger{
+		log:        log,
+		packetType: packetType,
+		receiveMTU: receiveMTU,
+	}
+}
+
+// Drop records a dropped packet.
+func (l *oversizedPacketLogger) Drop() {
+	observeOversizedPacket(l.packetType)
+
+	if l.logged {
+		return
+	}
+
+	l.logged = true
+
+	l.log.Warn("Dropping oversized packets", logger.Ctx{
+		"packet_type": l.packetType,
+		"receive_mtu": l.receiveMTU,
+	})
+}
+
+type readFunc func([]byte, interceptor.Attributes) (int, interceptor.Attributes, error)
+
+// readSkippingOversized reads the next packet that fits into b. Packets that
+// do not fit are discarded by packetio.Buffer, so the read is retried.
+func readSkippingOversized(read readFunc, b []byte, packetType string) (int, interceptor.Attributes, error) {
+	for {
+		i, a, err := read(b, interceptor.Attributes{})
+		if multierr.Is(err, io.ErrShortBuffer) {
+			observeOversizedPacket(packetType)
+
+			continue
+		}
+
+		return i, a, err
+	}
+}
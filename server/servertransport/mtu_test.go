@@ -0,0 +1,39 @@
+package servertransport
+
+import (
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/transport/packetio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSkippingOversized(t *testing.T) {
+	t.Parallel()
+
+	buffer := packetio.NewBuffer()
+
+	_, err := buffer.Write([]byte{1, 2, 3, 4, 5})
+	require.NoError(t, err)
+
+	_, err = buffer.Write([]byte{6, 7})
+	require.NoError(t, err)
+
+	read := func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		i, err := buffer.Read(b)
+
+		return i, a, err
+	}
+
+	b := make([]byte, 4)
+
+	i, _, err := readSkippingOversized(read, b, packetTypeRTP)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{6, 7}, b[:i])
+
+	require.NoError(t, buffer.Close())
+
+	_, _, err = readSkippingOversized(read, b, packetTypeRTP)
+	assert.Error(t, err)
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type rtcpReader struct {
-	buffer *packetio.Buffer
+	buffer     *packetio.Buffer
+	receiveMTU int
 
 	interceptor           interceptor.Interceptor
 	interceptorRTCPReader interceptor.RTCPReader
@@ -17,9 +18,10 @@ type rtcpReader struct {
 
 var _ transport.RTCPReader = &rtcpReader{}
 
-func newRTCPReader(buffer *packetio.Buffer, i interceptor.Interceptor) *rtcpReader {
+func newRTCPReader(buffer *packetio.Buffer, i interceptor.Interceptor, receiveMTU int) *rtcpReader {
 	s := &rtcpReader{
 		buffer:      buffer,
+		receiveMTU:  receiveMTU,
 		interceptor: i,
 	}
 
@@ -29,9 +31,9 @@ func newRTCPReader(buffer *packetio.Buffer, i interceptor.Interceptor) *rtcpRead
 }
 
 func (s *rtcpReader) ReadRTCP() ([]rtcp.Packet, interceptor.Attributes, error) {
-	b := make([]byte, ReceiveMTU)
+	b := make([]byte, s.receiveMTU)
 
-	i, a, err := s.interceptorRTCPReader.Read(b, interceptor.Attributes{})
+	i, a, err := readSkippingOversized(s.interceptorRTCPReader.Read, b, packetTypeRTCP)
 	if err != nil {
 		return nil, nil, errors.Annotatef(err, "reading RTCP")
 	}
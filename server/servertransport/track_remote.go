@@ -17,6 +17,7 @@ type trackRemote struct {
 	ssrc        webrtc.SSRC
 	track       transport.Track
 	interceptor interceptor.Interceptor
+	receiveMTU  int
 
 	onSub   func() error
 	onUnsub func() error
@@ -35,6 +36,7 @@ func newTrackRemote(
 	codec transport.Codec,
 	ceptor interceptor.Interceptor,
 	interceptorParameters codecs.InterceptorParams,
+	receiveMTU int,
 	onSub func() error,
 	onUnsub func() error,
 ) *trackRemote {
@@ -44,6 +46,7 @@ func newTrackRemote(
 		ssrc:        ssrc,
 		track:       track,
 		interceptor: ceptor,
+		receiveMTU:  receiveMTU,
 		onSub:       onSub,
 		onUnsub:     onUnsub,
 	}
@@ -91,9 +94,9 @@ func (t *trackRemote) read(in []byte, a interceptor.Attributes) (int, intercepto
 }
 
 func (t *trackRemote) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
-	b := make([]byte, ReceiveMTU)
+	b := make([]byte, t.receiveMTU)
 
-	i, a, err := t.interceptorRTPReader.Read(b, interceptor.Attributes{})
+	i, a, err := readSkippingOversized(t.interceptorRTPReader.Read, b, packetTypeRTP)
 	if err != nil {
 		return nil, nil, errors.Annotatef(err, "read RTP")
 	}
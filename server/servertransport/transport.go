@@ -16,8 +16,9 @@ import (
 )
 
 const (
-	// TODO reduce this.
-	ReceiveMTU int = 8192
+	// DefaultReceiveMTU is the default size of the buffers used for reading
+	// packets from the remote side.
+	DefaultReceiveMTU int = 8192
 )
 
 var (
@@ -66,6 +67,8 @@ type Params struct {
 	MetadataConn        io.ReadWriteCloser
 	CodecRegistry       *codecs.Registry
 	InterceptorRegistry *interceptor.Registry
+	// ReceiveMTU is optional. DefaultReceiveMTU will be used when zero.
+	ReceiveMTU int
 }
 
 func New(params Params) *Transport {
@@ -79,6 +82,10 @@ func New(params Params) *Transport {
 		params.CodecRegistry = codecs.NewRegistryDefault()
 	}
 
+	if params.ReceiveMTU == 0 {
+		params.ReceiveMTU = DefaultReceiveMTU
+	}
+
 	clientID := identifiers.ClientID(fmt.Sprintf("%s%s", identifiers.ServerNodePrefix, uuid.New()))
 	log := params.Log.WithNamespaceAppended("server_transport").WithCtx(logger.Ctx{
 		"client_id": clientID,
@@ -90,6 +97,7 @@ func New(params Params) *Transport {
 		Conn:          params.MediaConn,
 		Interceptor:   interceptor,
 		BufferFactory: nil,
+		ReceiveMTU:    params.ReceiveMTU,
 	})
 
 	metadataTransportParams := MetadataTransportParams{
@@ -99,11 +107,13 @@ func New(params Params) *Transport {
 		ClientID:      clientID,
 		Interceptor:   interceptor,
 		CodecRegistry: params.CodecRegistry,
+		ReceiveMTU:    params.ReceiveMTU,
 	}
 
 	dataTransportParams := DataTransportParams{
-		Log:  log,
-		Conn: params.DataConn,
+		Log:        log,
+		Conn:       params.DataConn,
+		ReceiveMTU: params.ReceiveMTU,
 	}
 
 	return &Transport{
@@ -12,6 +12,7 @@ import (
 	"github.com/peer-calls/peer-calls/v4/server/pionlogger"
 	"github.com/peer-calls/peer-calls/v4/server/test"
 	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/rtp/codecs"
 	"github.com/pion/sctp"
@@ -175,7 +176,7 @@ func TestTransport_AddTrack(t *testing.T) {
 	log.Info("Got remote track, subscribing", nil)
 
 	packetizer := rtp.NewPacketizer(
-		ReceiveMTU,
+		DefaultReceiveMTU,
 		0,
 		0,
 		&codecs.OpusPayloader{},
@@ -213,9 +214,39 @@ func TestTransport_AddTrack(t *testing.T) {
 
 	log.Info("Read RTP", nil)
 
+	goodbye := make(chan struct{})
+
+	go func() {
+		defer close(goodbye)
+
+		for {
+			packets, _, err := trwr.RTCPReader.ReadRTCP()
+			fmt.Printf("DEBUG %T %+v %v\n", packets, packets, err)
+			if err != nil {
+				assert.Fail(t, "expected RTCP BYE", "error: %+v", err)
+
+				return
+			}
+
+			for _, packet := range packets {
+				if bye, ok := packet.(*rtcp.Goodbye); ok {
+					assert.Equal(t, []uint32{uint32(remoteTrack.SSRC())}, bye.Sources)
+
+					return
+				}
+			}
+		}
+	}()
+
 	err = t1.RemoveTrack(localTrack.Track().TrackID())
 	assert.NoError(t, err, "removing track")
 
+	select {
+	case <-goodbye:
+	case <-time.After(time.Second):
+		assert.Fail(t, "timed out waiting for RTCP BYE")
+	}
+
 	// Track should end here.
 	for {
 		_, _, err := remoteTrack.ReadRTP()
@@ -312,7 +343,7 @@ func TestServerMediaTransport_SCTP_ClientClient(t *testing.T) {
 		var err error
 		c1, err = sctp.Client(sctp.Config{
 			NetConn:              conn1,
-			MaxReceiveBufferSize: uint32(ReceiveMTU),
+			MaxReceiveBufferSize: uint32(DefaultReceiveMTU),
 			MaxMessageSize:       0,
 			LoggerFactory:        plf,
 		})
@@ -326,7 +357,7 @@ func TestServerMediaTransport_SCTP_ClientClient(t *testing.T) {
 		var err error
 		c2, err = sctp.Client(sctp.Config{
 			NetConn:              conn2,
-			MaxReceiveBufferSize: uint32(ReceiveMTU),
+			MaxReceiveBufferSize: uint32(DefaultReceiveMTU),
 			MaxMessageSize:       0,
 			LoggerFactory:        plf,
 		})
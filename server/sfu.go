@@ -31,7 +31,9 @@ func NewSFUHandler(
 
 	webRTCTransportFactory := NewWebRTCTransportFactory(log, iceServers, sfuConfig)
 
-	return &SFU{log, wss, tracksManager, webRTCTransportFactory}
+	peerStateWebhook := NewPeerStateWebhook(log, sfuConfig.PeerStateWebhookURL)
+
+	return &SFU{log, wss, tracksManager, webRTCTransportFactory, peerStateWebhook}
 }
 
 type SFU struct {
@@ -40,6 +42,16 @@ type SFU struct {
 	tracksManager TracksManager
 
 	webRTCTransportFactory *WebRTCTransportFactory
+
+	// peerStateWebhook is nil when not configured.
+	peerStateWebhook *PeerStateWebhook
+}
+
+// Close stops the peer state webhook.
+func (sfu *SFU) Close() {
+	if sfu.peerStateWebhook != nil {
+		sfu.peerStateWebhook.Close()
+	}
 }
 
 func (sfu *SFU) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -61,9 +73,11 @@ func (sfu *SFU) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		log,
 		sfu.tracksManager,
 		sfu.webRTCTransportFactory,
+		sfu.peerStateWebhook,
 		clientID,
 		roomID,
 		sub.Adapter(),
+		sub.RestoredMetadata(),
 	)
 
 	// Just in case. I'm actually not sure if this is necessary since if the
@@ -86,9 +100,18 @@ type SocketHandler struct {
 	tracksManager          TracksManager
 	webRTCTransportFactory *WebRTCTransportFactory
 	webRTCTransport        *WebRTCTransport
+	peerStateWebhook       *PeerStateWebhook
 	adapter                Adapter
 	clientID               identifiers.ClientID
 	room                   identifiers.RoomID
+	restoredMetadata       string
+
+	// micMu guards the microphone state reported by the client and the last
+	// audio activity. They are only used for advisory mic hints.
+	micMu         sync.Mutex
+	muteReported  bool
+	muted         bool
+	audioActivity sfu.AudioActivity
 
 	mu sync.Mutex
 }
@@ -97,17 +120,21 @@ func NewSocketHandler(
 	log logger.Logger,
 	tracksManager TracksManager,
 	webRTCTransportFactory *WebRTCTransportFactory,
+	peerStateWebhook *PeerStateWebhook,
 	clientID identifiers.ClientID,
 	room identifiers.RoomID,
 	adapter Adapter,
+	restoredMetadata string,
 ) *SocketHandler {
 	return &SocketHandler{
 		log:                    log.WithNamespaceAppended("sfu"),
 		tracksManager:          tracksManager,
 		webRTCTransportFactory: webRTCTransportFactory,
+		peerStateWebhook:       peerStateWebhook,
 		clientID:               clientID,
 		room:                   room,
 		adapter:                adapter,
+		restoredMetadata:       restoredMetadata,
 	}
 }
 
@@ -126,6 +153,8 @@ func (sh *SocketHandler) HandleMessage(msg message.Message) error {
 		err = errors.Trace(sh.handleSignal(*msg.Payload.Signal))
 	case message.TypeSubTrack:
 		err = errors.Trace(sh.handleSubTrackEvent(*msg.Payload.SubTrack))
+	case message.TypeMute:
+		sh.handleMute(*msg.Payload.Mute)
 	case message.TypePing:
 	default:
 		err = errors.Errorf("Unhandled event: %+v", msg)
@@ -213,7 +242,7 @@ func (sh *SocketHandler) handleReady(msg message.Ready) error {
 		return errors.Errorf("unexpected ready event in room %s - already have a webrtc transport", roomID)
 	}
 
-	adapter.SetMetadata(clientID, msg.Nickname)
+	adapter.SetMetadata(clientID, readyMetadata(msg.Nickname, sh.restoredMetadata))
 
 	clients, err := getReadyClients(adapter)
 	if err != nil {
@@ -236,6 +265,13 @@ func (sh *SocketHandler) handleReady(msg message.Ready) error {
 		return errors.Annotatef(err, "create new WebRTCTransport")
 	}
 
+	// The transitions are performed from the ICE callbacks, which should not
+	// wait for the adapter.
+	peerStates := newPeerStateQueue()
+	webRTCTransport.StateMachine().OnTransition(peerStates.Push)
+
+	go peerStates.Process(sh.handlePeerStateTransition)
+
 	pubTrackEventsCh, err := sh.tracksManager.Add(roomID, webRTCTransport)
 	if err != nil {
 		webRTCTransport.Close()
@@ -260,10 +296,109 @@ func (sh *SocketHandler) handleReady(msg message.Ready) error {
 	}()
 
 	go sh.processLocalSignals(webRTCTransport.SignalChannel())
+	go sh.processAudioActivity(webRTCTransport)
 
 	return nil
 }
 
+// handlePeerStateTransition notifies the client and the webhook about the
+// state of the server-side peer connection.
+func (sh *SocketHandler) handlePeerStateTransition(transition PeerStateTransition) {
+	sh.log.Info("Peer state transition", logger.Ctx{
+		"from": transition.From,
+		"to":   transition.To,
+	})
+
+	err := sh.adapter.Emit(sh.clientID, message.NewPeerState(sh.room, message.PeerState{
+		From: transition.From.String(),
+		To:   transition.To.String(),
+	}))
+	if err != nil {
+		sh.log.Error("Emit peer state", errors.Trace(err), nil)
+	}
+
+	if sh.peerStateWebhook != nil {
+		sh.peerStateWebhook.Send(PeerStateWebhookEvent{
+			RoomID:    sh.room,
+			ClientID:  sh.clientID,
+			From:      transition.From.String(),
+			To:        transition.To.String(),
+			Timestamp: transition.Timestamp,
+		})
+	}
+}
+
+func (sh *SocketHandler) handleMute(mute message.Mute) {
+	sh.log.Info("mute event", logger.Ctx{
+		"muted": mute.Muted,
+	})
+
+	sh.micMu.Lock()
+
+	sh.muteReported = true
+	sh.muted = mute.Muted
+	// Muting in the middle of speech should produce a hint right away, since
+	// the muted track will not report any more speech. Unmuting is not checked
+	// because the last activity was most likely caused by the mute itself.
+	talkingWhileMuted := sh.muted && sh.audioActivity == sfu.AudioActivitySpeaking
+
+	sh.micMu.Unlock()
+
+	if talkingWhileMuted {
+		sh.emitMicHint(message.MicHintTypeTalkingWhileMuted)
+	}
+}
+
+// processAudioActivity sends a mic hint to the client when its sustained
+// audio activity contradicts the mute state: speech while muted, or silence
+// while unmuted. No hints are sent until the client reports its mute state.
+func (sh *SocketHandler) processAudioActivity(webRTCTransport *WebRTCTransport) {
+	activityCh := webRTCTransport.AudioActivityChannel()
+
+	for {
+		var event AudioActivityEvent
+
+		select {
+		case event = <-activityCh:
+		case <-webRTCTransport.Done():
+			return
+		}
+
+		sh.micMu.Lock()
+
+		sh.audioActivity = event.Activity
+
+		var hintType message.MicHintType
+
+		switch {
+		case !sh.muteReported:
+		case event.Activity == sfu.AudioActivitySpeaking && sh.muted:
+			hintType = message.MicHintTypeTalkingWhileMuted
+		case event.Activity == sfu.AudioActivitySilent && !sh.muted:
+			hintType = message.MicHintTypeSilent
+		}
+
+		sh.micMu.Unlock()
+
+		if hintType != "" {
+			sh.emitMicHint(hintType)
+		}
+	}
+}
+
+func (sh *SocketHandler) emitMicHint(hintType message.MicHintType) {
+	sh.log.Info("Emit mic hint", logger.Ctx{
+		"type": hintType,
+	})
+
+	err := sh.adapter.Emit(sh.clientID, message.NewMicHint(sh.room, message.MicHint{
+		Type: hintType,
+	}))
+	if err != nil {
+		sh.log.Error("Emit mic hint", errors.Trace(err), nil)
+	}
+}
+
 func (sh *SocketHandler) handleSignal(signal message.UserSignal) error {
 	if sh.webRTCTransport == nil {
 		return errors.Errorf("signal: webRTCTransport not initialized")
@@ -0,0 +1,163 @@
+package sfu
+
+import (
+	"time"
+)
+
+const (
+	// DefaultAudioLevelThreshold is the audio level (in -dBov) at or below
+	// which a packet is considered to contain speech. 0 is the loudest and
+	// 127 is silence.
+	DefaultAudioLevelThreshold uint8 = 50
+	// DefaultSilenceLevelThreshold is the audio level (in -dBov) at or above
+	// which a packet is considered to contain near-digital silence, as sent
+	// by a muted or broken microphone. Levels between the two thresholds are
+	// background noise, which is neither speech nor silence.
+	DefaultSilenceLevelThreshold uint8 = 120
+	// DefaultSpeakingDuration is the duration of sustained audio energy after
+	// which a peer is considered to be speaking.
+	DefaultSpeakingDuration = 2 * time.Second
+	// DefaultSilenceDuration is the duration of sustained silence after which
+	// a microphone is considered to be silent.
+	DefaultSilenceDuration = 30 * time.Second
+	// DefaultSpeechHangover is the maximum gap between two loud packets that
+	// is still considered a part of continuous speech.
+	DefaultSpeechHangover = 500 * time.Millisecond
+)
+
+// AudioActivity describes sustained audio activity of a single track.
+type AudioActivity int
+
+const (
+	// AudioActivityNone is the initial state before sustained activity or
+	// silence has been detected.
+	AudioActivityNone AudioActivity = iota
+	// AudioActivitySpeaking is reported after sustained audio energy.
+	AudioActivitySpeaking
+	// AudioActivitySilent is reported after sustained silence.
+	AudioActivitySilent
+)
+
+func (a AudioActivity) String() string {
+	switch a {
+	case AudioActivityNone:
+		return "none"
+	case AudioActivitySpeaking:
+		return "speaking"
+	case AudioActivitySilent:
+		return "silent"
+	default:
+		return "unknown"
+	}
+}
+
+type AudioLevelDetectorParams struct {
+	Threshold        uint8
+	SilenceThreshold uint8
+	SpeakingDuration time.Duration
+	SilenceDuration  time.Duration
+	SpeechHangover   time.Duration
+}
+
+// AudioLevelDetector detects sustained speech or silence from the audio
+// levels received in the RFC 6464 RTP header extension. It is not safe for
+// concurrent use.
+type AudioLevelDetector struct {
+	params AudioLevelDetectorParams
+
+	activity AudioActivity
+
+	speechStart time.Time
+	lastSpeech  time.Time
+	silentSince time.Time
+}
+
+// NewAudioLevelDetector creates a new instance of AudioLevelDetector. Zero
+// params are replaced by defaults.
+func NewAudioLevelDetector(params AudioLevelDetectorParams) *AudioLevelDetector {
+	if params.Threshold == 0 {
+		params.Threshold = DefaultAudioLevelThreshold
+	}
+
+	if params.SilenceThreshold == 0 {
+		params.SilenceThreshold = DefaultSilenceLevelThreshold
+	}
+
+	if params.SpeakingDuration == 0 {
+		params.SpeakingDuration = DefaultSpeakingDuration
+	}
+
+	if params.SilenceDuration == 0 {
+		params.SilenceDuration = DefaultSilenceDuration
+	}
+
+	if params.SpeechHangover == 0 {
+		params.SpeechHangover = DefaultSpeechHangover
+	}
+
+	return &AudioLevelDetector{
+		params: params,
+	}
+}
+
+// Feed records the audio level of a packet received at time now. It returns
+// the new activity and true when the activity has changed.
+func (d *AudioLevelDetector) Feed(level uint8, now time.Time) (AudioActivity, bool) {
+	if level <= d.params.Threshold {
+		d.silentSince = time.Time{}
+
+		if d.speechStart.IsZero() || now.Sub(d.lastSpeech) > d.params.SpeechHangover {
+			d.speechStart = now
+		}
+
+		d.lastSpeech = now
+
+		if now.Sub(d.speechStart) >= d.params.SpeakingDuration {
+			return d.setActivity(AudioActivitySpeaking)
+		}
+
+		return d.activity, false
+	}
+
+	if now.Sub(d.lastSpeech) <= d.params.SpeechHangover {
+		// Short pauses are a part of speech.
+		return d.activity, false
+	}
+
+	d.speechStart = time.Time{}
+
+	if level < d.params.SilenceThreshold {
+		// Background noise means that the microphone works, but nobody is
+		// speaking.
+		d.silentSince = time.Time{}
+
+		return d.setActivity(AudioActivityNone)
+	}
+
+	if d.silentSince.IsZero() {
+		d.silentSince = now
+	}
+
+	if now.Sub(d.silentSince) >= d.params.SilenceDuration {
+		return d.setActivity(AudioActivitySilent)
+	}
+
+	if d.activity == AudioActivitySpeaking {
+		return d.setActivity(AudioActivityNone)
+	}
+
+	return d.activity, false
+}
+
+// Activity returns the current activity.
+func (d *AudioLevelDetector) Activity() AudioActivity {
+	return d.activity
+}
+
+func (d *AudioLevelDetector) setActivity(activity AudioActivity) (AudioActivity, bool) {
+	changed := d.activity != activity
+
+	d.activity = activity
+
+	return activity, changed
+}
@@ -0,0 +1,126 @@
+package sfu_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	levelLoud   uint8 = 30
+	levelNoise  uint8 = 80
+	levelSilent uint8 = 127
+)
+
+func TestAudioLevelDetector_speaking(t *testing.T) {
+	t.Parallel()
+
+	d := sfu.NewAudioLevelDetector(sfu.AudioLevelDetectorParams{})
+
+	now := time.Unix(1600000000, 0)
+
+	var changes []sfu.AudioActivity
+
+	// 3 seconds of speech with a short pause in the middle.
+	for i := 0; i < 150; i++ {
+		level := levelLoud
+		if i >= 50 && i < 60 {
+			level = levelSilent
+		}
+
+		if activity, changed := d.Feed(level, now.Add(time.Duration(i)*20*time.Millisecond)); changed {
+			changes = append(changes, activity)
+		}
+	}
+
+	assert.Equal(t, []sfu.AudioActivity{sfu.AudioActivitySpeaking}, changes)
+	assert.Equal(t, sfu.AudioActivitySpeaking, d.Activity())
+}
+
+func TestAudioLevelDetector_shortBursts(t *testing.T) {
+	t.Parallel()
+
+	d := sfu.NewAudioLevelDetector(sfu.AudioLevelDetectorParams{})
+
+	now := time.Unix(1600000000, 0)
+
+	// One second of noise followed by one second of silence, repeated. The
+	// pauses are longer than the speech hangover so speech never lasts long
+	// enough.
+	for i := 0; i < 500; i++ {
+		level := levelSilent
+		if (i/50)%2 == 0 {
+			level = levelLoud
+		}
+
+		_, changed := d.Feed(level, now.Add(time.Duration(i)*20*time.Millisecond))
+		assert.False(t, changed, "packet %d", i)
+	}
+
+	assert.Equal(t, sfu.AudioActivityNone, d.Activity())
+}
+
+func TestAudioLevelDetector_silent(t *testing.T) {
+	t.Parallel()
+
+	d := sfu.NewAudioLevelDetector(sfu.AudioLevelDetectorParams{
+		SilenceDuration: time.Second,
+	})
+
+	now := time.Unix(1600000000, 0)
+
+	var changes []sfu.AudioActivity
+
+	feed := func(level uint8, from, to int) {
+		for i := from; i < to; i++ {
+			if activity, changed := d.Feed(level, now.Add(time.Duration(i)*20*time.Millisecond)); changed {
+				changes = append(changes, activity)
+			}
+		}
+	}
+
+	feed(levelLoud, 0, 150)
+	feed(levelSilent, 150, 250)
+	feed(levelLoud, 250, 400)
+
+	assert.Equal(t, []sfu.AudioActivity{
+		sfu.AudioActivitySpeaking,
+		sfu.AudioActivityNone,
+		sfu.AudioActivitySilent,
+		sfu.AudioActivitySpeaking,
+	}, changes)
+}
+
+func TestAudioLevelDetector_backgroundNoise(t *testing.T) {
+	t.Parallel()
+
+	d := sfu.NewAudioLevelDetector(sfu.AudioLevelDetectorParams{
+		SilenceDuration: time.Second,
+	})
+
+	now := time.Unix(1600000000, 0)
+
+	var changes []sfu.AudioActivity
+
+	feed := func(level uint8, from, to int) {
+		for i := from; i < to; i++ {
+			if activity, changed := d.Feed(level, now.Add(time.Duration(i)*20*time.Millisecond)); changed {
+				changes = append(changes, activity)
+			}
+		}
+	}
+
+	// Background noise is neither speech nor silence.
+	feed(levelNoise, 0, 200)
+	assert.Empty(t, changes)
+
+	feed(levelSilent, 200, 300)
+	feed(levelNoise, 300, 400)
+
+	assert.Equal(t, []sfu.AudioActivity{
+		sfu.AudioActivitySilent,
+		sfu.AudioActivityNone,
+	}, changes)
+}
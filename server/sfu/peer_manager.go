@@ -17,6 +17,16 @@ import (
 
 var ErrDuplicateTransport = errors.New("duplicate transport")
 
+type PeerManagerParams struct {
+	Room          identifiers.RoomID
+	Log           logger.Logger
+	JitterHandler JitterHandler
+	// Thumbnailer is optional.
+	Thumbnailer *Thumbnailer
+	// SimulcastLadders is optional. The default ladder is used when nil.
+	SimulcastLadders *SimulcastLadders
+}
+
 type PeerManager struct {
 	log logger.Logger
 	mu  sync.RWMutex
@@ -24,30 +34,56 @@ type PeerManager struct {
 
 	jitterHandler JitterHandler
 
+	// thumbnailer is optional.
+	thumbnailer *Thumbnailer
+
+	simulcastLadders *SimulcastLadders
+
 	// transports indexed by ClientID
 	transports map[identifiers.ClientID]transport.Transport
 
 	pliTimes map[identifiers.TrackID]time.Time
 
+	// temporalLayerFilters contains the filters of subscribed VP8 tracks
+	// indexed by TrackID and subscriber ClientID.
+	temporalLayerFilters map[identifiers.TrackID]map[identifiers.ClientID]*TemporalLayerFilter
+
+	// simulcastTracks contains the published simulcast tracks indexed by
+	// TrackID.
+	simulcastTracks map[identifiers.TrackID]*SimulcastTrack
+
 	room identifiers.RoomID
 
 	// pubsub keeps track of published tracks and its subscribers.
 	pubsub *pubsub.PubSub
 }
 
-func NewPeerManager(room identifiers.RoomID, log logger.Logger, jitterHandler JitterHandler) *PeerManager {
+func NewPeerManager(params PeerManagerParams) *PeerManager {
+	simulcastLadders := params.SimulcastLadders
+	if simulcastLadders == nil {
+		simulcastLadders = NewSimulcastLadders(nil, nil)
+	}
+
 	return &PeerManager{
-		log: log.WithNamespaceAppended("room_peers_manager"),
+		log: params.Log.WithNamespaceAppended("room_peers_manager"),
+
+		jitterHandler: params.JitterHandler,
+
+		thumbnailer: params.Thumbnailer,
 
-		jitterHandler: jitterHandler,
+		simulcastLadders: simulcastLadders,
 
 		transports: map[identifiers.ClientID]transport.Transport{},
 
 		pliTimes: map[identifiers.TrackID]time.Time{},
 
-		room: room,
+		temporalLayerFilters: map[identifiers.TrackID]map[identifiers.ClientID]*TemporalLayerFilter{},
 
-		pubsub: pubsub.New(log),
+		simulcastTracks: map[identifiers.TrackID]*SimulcastTrack{},
+
+		room: params.Room,
+
+		pubsub: pubsub.New(params.Log),
 	}
 }
 
@@ -137,35 +173,96 @@ func (t *PeerManager) Add(tr transport.Transport) (<-chan pubsub.PubTrackEvent,
 				rtcpReader := remoteTrackWithReceiver.RTCPReader
 				trackID := remoteTrack.Track().TrackID()
 
+				if remoteTrack.RID() != "" {
+					if simulcastTrack, ok := t.addSimulcastLayer(trackID, remoteTrack); ok {
+						log.Info("Added simulcast layer", logger.Ctx{
+							"track_id": trackID,
+							"rid":      remoteTrack.RID(),
+						})
+
+						t.wg.Add(1)
+
+						// Closing the track ends the published track, which unpublishes
+						// it.
+						go t.readRemoteRTCP(log, trackID, remoteTrack, rtcpReader, simulcastTrack.Close)
+
+						continue
+					}
+				}
+
 				done := make(chan struct{})
 
-				t.pubsub.Pub(clientID, pubsub.NewTrackReader(remoteTrack, func() {
-					t.mu.Lock()
+				if t.thumbnailer != nil {
+					remoteTrack = t.thumbnailer.Track(t.room, clientID, remoteTrack, func() {
+						t.mu.Lock()
+						pliAllowed := t.pliAllowed(trackID, time.Now())
+						t.mu.Unlock()
 
-					close(done)
+						if !pliAllowed {
+							return
+						}
 
-					t.pubsub.Unpub(clientID, trackID)
+						ssrc := uint32(remoteTrack.SSRC())
 
+						err := tr.WriteRTCP([]rtcp.Packet{
+							&rtcp.PictureLossIndication{
+								SenderSSRC: ssrc,
+								MediaSSRC:  ssrc,
+							},
+						})
+						if err != nil {
+							log.Error("Request keyframe for thumbnail", errors.Trace(err), nil)
+						}
+					})
+				}
+
+				var (
+					publishedTrack = remoteTrack
+					simulcastTrack *SimulcastTrack
+				)
+
+				if remoteTrack.RID() != "" {
+					simulcastTrack = NewSimulcastTrack(remoteTrack)
+					publishedTrack = simulcastTrack
+
+					t.mu.Lock()
+					t.simulcastTracks[trackID] = simulcastTrack
 					t.mu.Unlock()
-				}))
+				}
 
-				t.wg.Add(1)
+				var unpubOnce sync.Once
 
-				go func() {
-					defer t.wg.Done()
+				// unpub is called either when the track ends, or when the publisher
+				// sends an RTCP BYE, whichever happens first.
+				unpub := func() {
+					unpubOnce.Do(func() {
+						t.mu.Lock()
 
-					for {
-						// ReadRTCP ensures interceptors will do their work.
-						_, _, err := rtcpReader.ReadRTCP()
-						if err != nil {
-							if !multierr.Is(err, io.EOF) {
-								log.Error("ReadRTCP from receiver", errors.Trace(err), nil)
-							}
+						close(done)
 
-							return
+						t.pubsub.Unpub(clientID, trackID)
+
+						if simulcastTrack != nil && t.simulcastTracks[trackID] == simulcastTrack {
+							delete(t.simulcastTracks, trackID)
 						}
-					}
-				}()
+
+						t.mu.Unlock()
+
+						if simulcastTrack != nil {
+							simulcastTrack.Close()
+						}
+
+						if t.thumbnailer != nil {
+							t.thumbnailer.Remove(t.room, trackID)
+						}
+					})
+				}
+
+				t.pubsub.Pub(clientID, pubsub.NewTrackReader(publishedTrack, unpub))
+
+				t.wg.Add(1)
+
+				go t.readRemoteRTCP(log, trackID, remoteTrack, rtcpReader, unpub)
 
 				t.wg.Add(1)
 
@@ -187,30 +284,54 @@ func (t *PeerManager) Add(tr transport.Transport) (<-chan pubsub.PubTrackEvent,
 							return 0, false
 						}
 
-						return estimator.Min(), true
-					}
+						if remoteTrack.RID() == "" {
+							if t.hasTemporalLayers(trackID) {
+								// Subscribers with a lower estimate will receive only the lower
+								// temporal layers.
+								return estimator.Max(), true
+							}
 
-					select {
-					case <-ticker.C:
-						bitrate, ok := getBitrateEstimate()
-						if !ok {
-							break
+							return estimator.Min(), true
 						}
 
-						ssrc := uint32(remoteTrack.SSRC())
+						// A simulcast publisher should send all layers up to the layer
+						// selected for the subscriber with the best connection. Others
+						// will receive the lower layers.
+						ladder := t.simulcastLadders.Ladder(t.room)
+						if len(ladder) == 0 {
+							return estimator.Max(), true
+						}
 
-						// FIXME simulcast?
+						return ladder.PublisherBitrate(estimator.Max()), true
+					}
 
-						err := tr.WriteRTCP([]rtcp.Packet{
-							&rtcp.ReceiverEstimatedMaximumBitrate{
-								SenderSSRC: ssrc,
-								Bitrate:    bitrate,
-								SSRCs:      []uint32{ssrc},
-							},
-						})
-						_ = err // FIXME handle error
+					for {
+						select {
+						case <-ticker.C:
+							bitrate, ok := getBitrateEstimate()
+							if !ok {
+								break
+							}
 
-					case <-done:
+							ssrc := uint32(remoteTrack.SSRC())
+							ssrcs := []uint32{ssrc}
+
+							if simulcastTrack != nil {
+								ssrcs = simulcastTrack.SSRCs()
+							}
+
+							err := tr.WriteRTCP([]rtcp.Packet{
+								&rtcp.ReceiverEstimatedMaximumBitrate{
+									SenderSSRC: ssrc,
+									Bitrate:    bitrate,
+									SSRCs:      ssrcs,
+								},
+							})
+							_ = err // FIXME handle error
+
+						case <-done:
+							return
+						}
 					}
 				}()
 			case <-doneCh:
@@ -263,6 +384,98 @@ func (t *PeerManager) add(tr transport.Transport) (<-chan pubsub.PubTrackEvent,
 	return pubTrackEventSub, nil
 }
 
+// addSimulcastLayer adds the layer to an already published simulcast track.
+// It returns false when the track has not been published yet, or when it has
+// already ended.
+func (t *PeerManager) addSimulcastLayer(
+	trackID identifiers.TrackID,
+	layer transport.TrackRemote,
+) (*SimulcastTrack, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	simulcastTrack, ok := t.simulcastTracks[trackID]
+
+	return simulcastTrack, ok && simulcastTrack.AddLayer(layer)
+}
+
+// readRemoteRTCP reads the RTCP packets of a published track, or of a single
+// layer of a simulcast track, until the track ends. onGoodbye is called when
+// the publisher sends an RTCP BYE. The caller must call t.wg.Add(1).
+func (t *PeerManager) readRemoteRTCP(
+	log logger.Logger,
+	trackID identifiers.TrackID,
+	remoteTrack transport.TrackRemote,
+	rtcpReader transport.RTCPReader,
+	onGoodbye func(),
+) {
+	defer t.wg.Done()
+
+	for {
+		// ReadRTCP ensures interceptors will do their work.
+		packets, _, err := rtcpReader.ReadRTCP()
+		if err != nil {
+			if !multierr.Is(err, io.EOF) {
+				log.Error("ReadRTCP from receiver", errors.Trace(err), nil)
+			}
+
+			return
+		}
+
+		if isGoodbye(packets, uint32(remoteTrack.SSRC())) {
+			log.Info("Received RTCP BYE, unpublishing track", logger.Ctx{
+				"track_id": trackID,
+				"rid":      remoteTrack.RID(),
+			})
+
+			onGoodbye()
+		}
+	}
+}
+
+// requestKeyFrame sends a PLI for a single layer of a simulcast track to its
+// publisher.
+func (t *PeerManager) requestKeyFrame(trackID identifiers.TrackID, ssrc webrtc.SSRC) {
+	t.mu.Lock()
+
+	props, propsFound := t.pubsub.TrackPropsByTrackID(trackID)
+	tr, transportFound := t.transports[props.ClientID]
+	pliAllowed := propsFound && transportFound && t.pliAllowed(trackID, time.Now())
+
+	t.mu.Unlock()
+
+	if !pliAllowed {
+		return
+	}
+
+	err := tr.WriteRTCP([]rtcp.Packet{
+		&rtcp.PictureLossIndication{
+			SenderSSRC: uint32(ssrc),
+			MediaSSRC:  uint32(ssrc),
+		},
+	})
+	if err != nil {
+		t.log.Error("Request keyframe for simulcast layer", errors.Trace(err), logger.Ctx{
+			"client_id": props.ClientID,
+			"track_id":  trackID,
+		})
+	}
+}
+
+// pliAllowed returns true and records the time when no PLI has been sent to
+// the publisher of the track during the last second. The caller must hold the
+// lock.
+func (t *PeerManager) pliAllowed(trackID identifiers.TrackID, now time.Time) bool {
+	// TODO perhaps a better solution for this would be an RTCP interceptor.
+	if now.Sub(t.pliTimes[trackID]) < time.Second {
+		return false
+	}
+
+	t.pliTimes[trackID] = now
+
+	return true
+}
+
 func (t *PeerManager) Sub(params SubParams) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -272,16 +485,55 @@ func (t *PeerManager) Sub(params SubParams) error {
 		return errors.Errorf("transport not found: %s", params.PubClientID)
 	}
 
-	rtcpReader, err := t.pubsub.Sub(params.PubClientID, params.TrackID, tr)
+	temporalTransport := &temporalLayerTransport{Transport: tr}
+
+	var (
+		filteringTransport pubsub.Transport = temporalTransport
+		simulcastTransport *simulcastLayerTransport
+	)
+
+	if simulcastTrack, ok := t.simulcastTracks[params.TrackID]; ok {
+		simulcastTransport = &simulcastLayerTransport{
+			Transport: temporalTransport,
+			track:     simulcastTrack,
+			ladder:    t.simulcastLadders.Ladder(t.room),
+			requestKeyFrame: func(ssrc webrtc.SSRC) {
+				t.requestKeyFrame(params.TrackID, ssrc)
+			},
+		}
+
+		filteringTransport = simulcastTransport
+	}
+
+	rtcpReader, err := t.pubsub.Sub(params.PubClientID, params.TrackID, filteringTransport)
 	if err != nil {
 		return errors.Trace(err)
 	}
 
+	filter := temporalTransport.filter
+
+	var simulcastFilter *SimulcastLayerFilter
+
+	if simulcastTransport != nil {
+		simulcastFilter = simulcastTransport.filter
+	}
+	if filter != nil {
+		t.addTemporalLayerFilter(params.TrackID, params.SubClientID, filter)
+	}
+
 	t.wg.Add(1)
 
 	go func() {
 		defer t.wg.Done()
 
+		if filter != nil {
+			defer func() {
+				t.mu.Lock()
+				t.removeTemporalLayerFilter(params.TrackID, params.SubClientID, filter)
+				t.mu.Unlock()
+			}()
+		}
+
 		logCtx := logger.Ctx{
 			"pub_client_id": params.PubClientID,
 			"track_id":      params.TrackID,
@@ -297,6 +549,14 @@ func (t *PeerManager) Sub(params SubParams) error {
 			}
 
 			t.mu.Unlock()
+
+			if filter != nil {
+				filter.SetBitrateEstimate(bitrate)
+			}
+
+			if simulcastFilter != nil {
+				simulcastFilter.SetBitrateEstimate(bitrate)
+			}
 		}
 
 		forwardPLI := func(packet *rtcp.PictureLossIndication) error {
@@ -306,13 +566,7 @@ func (t *PeerManager) Sub(params SubParams) error {
 
 			props, propsFound := t.pubsub.TrackPropsByTrackID(params.TrackID)
 			transport, transportFound := t.transports[props.ClientID]
-			lastPLITime := t.pliTimes[params.TrackID]
-
-			// TODO perhaps a better solution for this would be an RTCP interceptor.
-			pliTooSoon := now.Sub(lastPLITime) < time.Second
-			if !pliTooSoon {
-				t.pliTimes[params.TrackID] = now
-			}
+			pliTooSoon := !t.pliAllowed(params.TrackID, now)
 
 			t.mu.Unlock()
 
@@ -330,9 +584,18 @@ func (t *PeerManager) Sub(params SubParams) error {
 				return nil
 			}
 
+			ssrc := props.SSRC
+
+			if simulcastFilter != nil {
+				// Request the keyframe of the layer the subscriber receives.
+				if currentSSRC, ok := simulcastFilter.CurrentSSRC(); ok {
+					ssrc = currentSSRC
+				}
+			}
+
 			// Important: set the correct SSRC before sending the packet to source.
-			packet.MediaSSRC = uint32(props.SSRC)
-			packet.SenderSSRC = uint32(props.SSRC)
+			packet.MediaSSRC = uint32(ssrc)
+			packet.SenderSSRC = uint32(ssrc)
 
 			if err := transport.WriteRTCP([]rtcp.Packet{packet}); err != nil {
 				return errors.Annotatef(err, "sending PLI back to source: %s", props.ClientID)
@@ -384,6 +647,73 @@ func (t *PeerManager) Sub(params SubParams) error {
 	return nil
 }
 
+// isGoodbye returns true when packets contain an RTCP BYE for ssrc.
+func isGoodbye(packets []rtcp.Packet, ssrc uint32) bool {
+	for _, packet := range packets {
+		bye, ok := packet.(*rtcp.Goodbye)
+		if !ok {
+			continue
+		}
+
+		for _, source := range bye.Sources {
+			if source == ssrc {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// addTemporalLayerFilter stores the filter of a subscribed track. The caller
+// must hold the lock.
+func (t *PeerManager) addTemporalLayerFilter(
+	trackID identifiers.TrackID,
+	subClientID identifiers.ClientID,
+	filter *TemporalLayerFilter,
+) {
+	filters, ok := t.temporalLayerFilters[trackID]
+	if !ok {
+		filters = map[identifiers.ClientID]*TemporalLayerFilter{}
+		t.temporalLayerFilters[trackID] = filters
+	}
+
+	filters[subClientID] = filter
+}
+
+// removeTemporalLayerFilter removes the filter of a subscribed track, unless
+// it has already been replaced by a new subscription. The caller must hold
+// the lock.
+func (t *PeerManager) removeTemporalLayerFilter(
+	trackID identifiers.TrackID,
+	subClientID identifiers.ClientID,
+	filter *TemporalLayerFilter,
+) {
+	filters := t.temporalLayerFilters[trackID]
+
+	if filters[subClientID] != filter {
+		return
+	}
+
+	delete(filters, subClientID)
+
+	if len(filters) == 0 {
+		delete(t.temporalLayerFilters, trackID)
+	}
+}
+
+// hasTemporalLayers returns true when any of the subscribers of the track
+// receives VP8 temporal layers. The caller must hold the lock.
+func (t *PeerManager) hasTemporalLayers(trackID identifiers.TrackID) bool {
+	for _, filter := range t.temporalLayerFilters[trackID] {
+		if filter.HasTemporalLayers() {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (t *PeerManager) Unsub(params SubParams) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
@@ -0,0 +1,194 @@
+package sfu
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/pubsub"
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsGoodbye(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, isGoodbye(nil, 1))
+	assert.False(t, isGoodbye([]rtcp.Packet{
+		&rtcp.PictureLossIndication{MediaSSRC: 1},
+	}, 1))
+	assert.False(t, isGoodbye([]rtcp.Packet{
+		&rtcp.Goodbye{Sources: []uint32{2}},
+	}, 1))
+	assert.True(t, isGoodbye([]rtcp.Packet{
+		&rtcp.ReceiverReport{SSRC: 3},
+		&rtcp.Goodbye{Sources: []uint32{2, 1}},
+	}, 1))
+}
+
+type rtcpReaderMock struct {
+	packets chan []rtcp.Packet
+}
+
+func newRTCPReaderMock() *rtcpReaderMock {
+	return &rtcpReaderMock{
+		packets: make(chan []rtcp.Packet),
+	}
+}
+
+func (r *rtcpReaderMock) ReadRTCP() ([]rtcp.Packet, interceptor.Attributes, error) {
+	packets, ok := <-r.packets
+	if !ok {
+		return nil, nil, io.EOF
+	}
+
+	return packets, nil, nil
+}
+
+type peerManagerTransportMock struct {
+	transport.Transport
+
+	clientID     identifiers.ClientID
+	remoteTracks chan transport.TrackRemoteWithRTCPReader
+	messages     chan webrtc.DataChannelMessage
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newPeerManagerTransportMock(clientID identifiers.ClientID) *peerManagerTransportMock {
+	return &peerManagerTransportMock{
+		clientID:     clientID,
+		remoteTracks: make(chan transport.TrackRemoteWithRTCPReader),
+		messages:     make(chan webrtc.DataChannelMessage),
+		done:         make(chan struct{}),
+	}
+}
+
+func (t *peerManagerTransportMock) ClientID() identifiers.ClientID {
+	return t.clientID
+}
+
+func (t *peerManagerTransportMock) Type() transport.Type {
+	return transport.TypeWebRTC
+}
+
+func (t *peerManagerTransportMock) RemoteTracksChannel() <-chan transport.TrackRemoteWithRTCPReader {
+	return t.remoteTracks
+}
+
+func (t *peerManagerTransportMock) MessagesChannel() <-chan webrtc.DataChannelMessage {
+	return t.messages
+}
+
+func (t *peerManagerTransportMock) WriteRTCP([]rtcp.Packet) error {
+	return nil
+}
+
+func (t *peerManagerTransportMock) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.messages)
+		close(t.done)
+	})
+
+	return nil
+}
+
+func (t *peerManagerTransportMock) Done() <-chan struct{} {
+	return t.done
+}
+
+func TestPeerManager_goodbye(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		rids []string
+		// byeLayer is the index of the layer that sends the BYE.
+		byeLayer int
+	}
+
+	testCases := map[string]testCase{
+		"track":                  {rids: []string{""}, byeLayer: 0},
+		"simulcast first layer":  {rids: []string{"q", "h"}, byeLayer: 0},
+		"simulcast second layer": {rids: []string{"q", "h"}, byeLayer: 1},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			peerManager := NewPeerManager(PeerManagerParams{
+				Room: "room",
+				Log:  test.NewLogger(),
+			})
+
+			pub := newPeerManagerTransportMock("pub")
+			sub := newPeerManagerTransportMock("sub")
+
+			_, err := peerManager.Add(pub)
+			require.NoError(t, err)
+
+			events, err := peerManager.Add(sub)
+			require.NoError(t, err)
+
+			defer func() {
+				for _, tr := range []*peerManagerTransportMock{pub, sub} {
+					tr.Close()
+					assert.NoError(t, peerManager.Remove(tr))
+				}
+
+				<-peerManager.Close()
+			}()
+
+			readers := make([]*rtcpReaderMock, len(tc.rids))
+
+			for i, rid := range tc.rids {
+				layer := newSimulcastLayerMock(webrtc.SSRC(i+1), rid)
+				readers[i] = newRTCPReaderMock()
+
+				defer close(layer.packets)
+				defer close(readers[i].packets)
+
+				pub.remoteTracks <- transport.TrackRemoteWithRTCPReader{
+					TrackRemote: layer,
+					RTCPReader:  readers[i],
+				}
+			}
+
+			readEvent := func() pubsub.PubTrackEvent {
+				select {
+				case event := <-events:
+					return event
+				case <-time.After(time.Second):
+					require.Fail(t, "timed out waiting for a track event")
+
+					return pubsub.PubTrackEvent{}
+				}
+			}
+
+			event := readEvent()
+			assert.Equal(t, transport.TrackEventTypeAdd, event.Type)
+			assert.Equal(t, identifiers.ClientID("pub"), event.PubTrack.ClientID)
+
+			ssrc := uint32(tc.byeLayer + 1)
+			reader := readers[tc.byeLayer]
+
+			// BYEs of other sources are ignored.
+			reader.packets <- []rtcp.Packet{&rtcp.Goodbye{Sources: []uint32{ssrc + 10}}}
+			reader.packets <- []rtcp.Packet{&rtcp.Goodbye{Sources: []uint32{ssrc}}}
+
+			event = readEvent()
+			assert.Equal(t, transport.TrackEventTypeRemove, event.Type)
+			assert.Equal(t, identifiers.ClientID("pub"), event.PubTrack.ClientID)
+		})
+	}
+}
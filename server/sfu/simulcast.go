@@ -0,0 +1,166 @@
+package sfu
+
+import (
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+)
+
+var ErrInvalidSimulcastLadder = errors.New("invalid simulcast ladder")
+
+// SimulcastLayer describes a single simulcast encoding the publishers should
+// send.
+type SimulcastLayer struct {
+	// RID is the restriction identifier of the encoding.
+	RID string `yaml:"rid" json:"rid"`
+	// ScaleResolutionDownBy is the factor by which the resolution of the
+	// original video is scaled down.
+	ScaleResolutionDownBy float64 `yaml:"scale_resolution_down_by" json:"scaleResolutionDownBy"`
+	// MaxBitrate is the maximum bitrate of the encoding in bits per second.
+	MaxBitrate uint64 `yaml:"max_bitrate" json:"maxBitrate"`
+}
+
+// SimulcastLadder contains simulcast layers ordered from the lowest to the
+// highest bitrate.
+type SimulcastLadder []SimulcastLayer
+
+// DefaultSimulcastLadder returns a ladder suitable for most rooms. It is not
+// enabled by default: rooms with an empty ladder do not use simulcast.
+func DefaultSimulcastLadder() SimulcastLadder {
+	return SimulcastLadder{
+		{RID: "q", ScaleResolutionDownBy: 4, MaxBitrate: 150000},
+		{RID: "h", ScaleResolutionDownBy: 2, MaxBitrate: 500000},
+		{RID: "f", ScaleResolutionDownBy: 1, MaxBitrate: 1500000},
+	}
+}
+
+// Validate returns an error when the RIDs are not unique, when the bitrates
+// are not increasing, or when a layer would upscale the video.
+func (l SimulcastLadder) Validate() error {
+	rids := make(map[string]struct{}, len(l))
+
+	for i, layer := range l {
+		if layer.RID == "" {
+			return errors.Annotatef(ErrInvalidSimulcastLadder, "layer %d: empty rid", i)
+		}
+
+		if _, ok := rids[layer.RID]; ok {
+			return errors.Annotatef(ErrInvalidSimulcastLadder, "layer %d: duplicate rid: %s", i, layer.RID)
+		}
+
+		rids[layer.RID] = struct{}{}
+
+		if layer.ScaleResolutionDownBy < 1 {
+			return errors.Annotatef(ErrInvalidSimulcastLadder, "layer %d: scale_resolution_down_by < 1", i)
+		}
+
+		if i > 0 && layer.MaxBitrate <= l[i-1].MaxBitrate {
+			return errors.Annotatef(ErrInvalidSimulcastLadder, "layer %d: max_bitrate not increasing", i)
+		}
+	}
+
+	return nil
+}
+
+// Layer finds the layer by RID.
+func (l SimulcastLadder) Layer(rid string) (SimulcastLayer, bool) {
+	for _, layer := range l {
+		if layer.RID == rid {
+			return layer, true
+		}
+	}
+
+	return SimulcastLayer{}, false
+}
+
+// Select returns the index of the highest layer that fits into the estimated
+// bitrate. The lowest layer is selected when none of the layers fit. It
+// returns -1 when the ladder is empty.
+func (l SimulcastLadder) Select(bitrate uint64) int {
+	if len(l) == 0 {
+		return -1
+	}
+
+	selected := 0
+
+	for i, layer := range l {
+		if layer.MaxBitrate <= bitrate {
+			selected = i
+		}
+	}
+
+	return selected
+}
+
+// PublisherBitrate returns the total bitrate a publisher needs to send all
+// layers up to the layer selected for the estimated bitrate.
+func (l SimulcastLadder) PublisherBitrate(bitrate uint64) uint64 {
+	var total uint64
+
+	for i := 0; i <= l.Select(bitrate); i++ {
+		total += l[i].MaxBitrate
+	}
+
+	return total
+}
+
+// SimulcastLadders keeps the simulcast ladders configured for each room.
+// It is safe for concurrent use.
+type SimulcastLadders struct {
+	mu sync.RWMutex
+
+	defaultLadder SimulcastLadder
+	rooms         map[identifiers.RoomID]SimulcastLadder
+}
+
+// NewSimulcastLadders creates a new instance of SimulcastLadders. The
+// defaultLadder is used for rooms without a custom ladder.
+func NewSimulcastLadders(
+	defaultLadder SimulcastLadder,
+	rooms map[identifiers.RoomID]SimulcastLadder,
+) *SimulcastLadders {
+	roomsCopy := make(map[identifiers.RoomID]SimulcastLadder, len(rooms))
+
+	for room, ladder := range rooms {
+		roomsCopy[room] = ladder
+	}
+
+	return &SimulcastLadders{
+		defaultLadder: defaultLadder,
+		rooms:         roomsCopy,
+	}
+}
+
+// Ladder returns the ladder of a room.
+func (s *SimulcastLadders) Ladder(room identifiers.RoomID) SimulcastLadder {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ladder, ok := s.rooms[room]; ok {
+		return ladder
+	}
+
+	return s.defaultLadder
+}
+
+// SetLadder sets a custom ladder for a room. A nil ladder restores the
+// default.
+func (s *SimulcastLadders) SetLadder(room identifiers.RoomID, ladder SimulcastLadder) error {
+	if err := ladder.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ladder == nil {
+		delete(s.rooms, room)
+
+		return nil
+	}
+
+	s.rooms[room] = ladder
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package sfu_test
+
+import (
+	"testing"
+
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulcastLadder_Validate(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, sfu.DefaultSimulcastLadder().Validate())
+	assert.NoError(t, sfu.SimulcastLadder(nil).Validate())
+
+	for name, ladder := range map[string]sfu.SimulcastLadder{
+		"empty rid": {
+			{RID: "", ScaleResolutionDownBy: 1, MaxBitrate: 100},
+		},
+		"duplicate rid": {
+			{RID: "a", ScaleResolutionDownBy: 2, MaxBitrate: 100},
+			{RID: "a", ScaleResolutionDownBy: 1, MaxBitrate: 200},
+		},
+		"upscale": {
+			{RID: "a", ScaleResolutionDownBy: 0.5, MaxBitrate: 100},
+		},
+		"bitrate not increasing": {
+			{RID: "a", ScaleResolutionDownBy: 2, MaxBitrate: 200},
+			{RID: "b", ScaleResolutionDownBy: 1, MaxBitrate: 200},
+		},
+	} {
+		err := ladder.Validate()
+		assert.True(t, multierr.Is(err, sfu.ErrInvalidSimulcastLadder), "%s: %+v", name, err)
+	}
+}
+
+func TestSimulcastLadder_Select(t *testing.T) {
+	t.Parallel()
+
+	ladder := sfu.DefaultSimulcastLadder()
+
+	assert.Equal(t, -1, sfu.SimulcastLadder(nil).Select(1000000))
+	assert.Equal(t, 0, ladder.Select(0))
+	assert.Equal(t, 0, ladder.Select(499999))
+	assert.Equal(t, 1, ladder.Select(500000))
+	assert.Equal(t, 2, ladder.Select(10000000))
+
+	assert.Equal(t, uint64(150000), ladder.PublisherBitrate(0))
+	assert.Equal(t, uint64(650000), ladder.PublisherBitrate(600000))
+	assert.Equal(t, uint64(2150000), ladder.PublisherBitrate(10000000))
+	assert.Equal(t, uint64(0), sfu.SimulcastLadder(nil).PublisherBitrate(10000000))
+
+	layer, ok := ladder.Layer("h")
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, layer.ScaleResolutionDownBy)
+
+	_, ok = ladder.Layer("x")
+	assert.False(t, ok)
+}
+
+func TestSimulcastLadders(t *testing.T) {
+	t.Parallel()
+
+	defaultLadder := sfu.DefaultSimulcastLadder()
+	room2Ladder := sfu.SimulcastLadder{
+		{RID: "l", ScaleResolutionDownBy: 2, MaxBitrate: 300000},
+		{RID: "h", ScaleResolutionDownBy: 1, MaxBitrate: 800000},
+	}
+
+	ladders := sfu.NewSimulcastLadders(defaultLadder, map[identifiers.RoomID]sfu.SimulcastLadder{
+		"room2": room2Ladder,
+	})
+
+	assert.Equal(t, defaultLadder, ladders.Ladder("room1"))
+	assert.Equal(t, room2Ladder, ladders.Ladder("room2"))
+
+	assert.NoError(t, ladders.SetLadder("room1", room2Ladder))
+	assert.Equal(t, room2Ladder, ladders.Ladder("room1"))
+
+	err := ladders.SetLadder("room1", sfu.SimulcastLadder{{}})
+	assert.True(t, multierr.Is(err, sfu.ErrInvalidSimulcastLadder))
+	assert.Equal(t, room2Ladder, ladders.Ladder("room1"))
+
+	assert.NoError(t, ladders.SetLadder("room1", nil))
+	assert.Equal(t, defaultLadder, ladders.Ladder("room1"))
+}
@@ -0,0 +1,417 @@
+package sfu
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/pubsub"
+	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// simulcastKeyFrameRequestInterval is the minimum interval between the
+// keyframe requests of a single SimulcastLayerFilter.
+const simulcastKeyFrameRequestInterval = time.Second
+
+type simulcastPacket struct {
+	packet     *rtp.Packet
+	attributes interceptor.Attributes
+}
+
+// SimulcastTrack merges the layers of a simulcast track into a single
+// transport.TrackRemote so that the track is published only once. The
+// packets of all layers are read, and subscribers select the layer they
+// receive by wrapping their tracks with a SimulcastLayerFilter.
+type SimulcastTrack struct {
+	// TrackRemote is the first layer of the track.
+	transport.TrackRemote
+
+	packets chan simulcastPacket
+	// done is closed after all layers have ended.
+	done chan struct{}
+	// closeCh is closed by Close.
+	closeCh   chan struct{}
+	closeOnce sync.Once
+
+	mu        sync.Mutex
+	closed    bool
+	numLayers int
+	// rids contains the RIDs of the layers indexed by SSRC.
+	rids map[webrtc.SSRC]string
+}
+
+var _ transport.TrackRemote = &SimulcastTrack{}
+
+// NewSimulcastTrack creates a new instance of SimulcastTrack and starts
+// reading from the first layer.
+func NewSimulcastTrack(layer transport.TrackRemote) *SimulcastTrack {
+	s := &SimulcastTrack{
+		TrackRemote: layer,
+		packets:     make(chan simulcastPacket),
+		done:        make(chan struct{}),
+		closeCh:     make(chan struct{}),
+		rids:        map[webrtc.SSRC]string{},
+	}
+
+	s.AddLayer(layer)
+
+	return s
+}
+
+// AddLayer starts reading from another layer of the track. It returns false
+// when all previously added layers have already ended.
+func (s *SimulcastTrack) AddLayer(layer transport.TrackRemote) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.rids[layer.SSRC()] = layer.RID()
+	s.numLayers++
+
+	go s.readLayer(layer)
+
+	return true
+}
+
+func (s *SimulcastTrack) readLayer(layer transport.TrackRemote) {
+	for {
+		packet, attributes, err := layer.ReadRTP()
+		if err != nil {
+			break
+		}
+
+		select {
+		case s.packets <- simulcastPacket{
+			packet:     packet,
+			attributes: attributes,
+		}:
+		case <-s.closeCh:
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.numLayers--
+
+	if s.numLayers == 0 {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+// ReadRTP reads the next packet of any layer. It returns io.EOF after all
+// layers have ended, or after the track has been closed.
+func (s *SimulcastTrack) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
+	// Prefer closeCh over the packets the layers are still trying to send.
+	select {
+	case <-s.closeCh:
+		return nil, nil, errors.Trace(io.EOF)
+	default:
+	}
+
+	select {
+	case p := <-s.packets:
+		return p.packet, p.attributes, nil
+	case <-s.done:
+		return nil, nil, errors.Trace(io.EOF)
+	case <-s.closeCh:
+		return nil, nil, errors.Trace(io.EOF)
+	}
+}
+
+// Close stops forwarding the packets of the layers so that the layers are
+// not blocked once the track is no longer read. New layers are not added
+// after Close.
+func (s *SimulcastTrack) Close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.closed = true
+		close(s.closeCh)
+	})
+}
+
+// LayerRID returns the RID of the layer with ssrc.
+func (s *SimulcastTrack) LayerRID(ssrc webrtc.SSRC) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rid, ok := s.rids[ssrc]
+
+	return rid, ok
+}
+
+// LayerSSRC returns the SSRC of the layer with rid.
+func (s *SimulcastTrack) LayerSSRC(rid string) (webrtc.SSRC, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ssrc, layerRID := range s.rids {
+		if layerRID == rid {
+			return ssrc, true
+		}
+	}
+
+	return 0, false
+}
+
+// SSRCs returns the SSRCs of all layers.
+func (s *SimulcastTrack) SSRCs() []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ssrcs := make([]uint32, 0, len(s.rids))
+
+	for ssrc := range s.rids {
+		ssrcs = append(ssrcs, uint32(ssrc))
+	}
+
+	return ssrcs
+}
+
+type SimulcastLayerFilterParams struct {
+	TrackLocal transport.TrackLocal
+	Track      *SimulcastTrack
+	Ladder     SimulcastLadder
+	// RequestKeyFrame is called from a new goroutine when the filter waits for
+	// a keyframe of the layer with ssrc before switching to it.
+	RequestKeyFrame func(ssrc webrtc.SSRC)
+}
+
+// SimulcastLayerFilter forwards a single layer of a SimulcastTrack to a
+// subscriber. The layer is selected from the ladder using the bitrate
+// estimate of the subscriber. Since the layers are encoded independently,
+// the filter switches to another layer only on its keyframes. Sequence
+// numbers and timestamps are rewritten so that the subscriber receives a
+// continuous stream.
+type SimulcastLayerFilter struct {
+	transport.TrackLocal
+
+	params SimulcastLayerFilterParams
+	isVP8  bool
+
+	mu sync.Mutex
+
+	// targetRID is the RID of the layer that fits into the bitrate estimate.
+	targetRID string
+	// currentRID is the RID of the layer being forwarded. It is empty until
+	// the first layer has been selected.
+	currentRID  string
+	currentSSRC webrtc.SSRC
+
+	seqOffset uint16
+	tsOffset  uint32
+
+	lastSeq       uint16
+	lastTimestamp uint32
+	lastWrite     time.Time
+
+	lastKeyFrameRequest time.Time
+}
+
+var _ transport.TrackLocal = &SimulcastLayerFilter{}
+
+// NewSimulcastLayerFilter creates a new instance of SimulcastLayerFilter. The
+// lowest layer is forwarded until a bitrate estimate is set.
+func NewSimulcastLayerFilter(params SimulcastLayerFilterParams) *SimulcastLayerFilter {
+	var targetRID string
+
+	if len(params.Ladder) > 0 {
+		targetRID = params.Ladder[0].RID
+	}
+
+	return &SimulcastLayerFilter{
+		TrackLocal: params.TrackLocal,
+		params:     params,
+		isVP8:      strings.EqualFold(params.Track.Track().Codec().MimeType, webrtc.MimeTypeVP8),
+		targetRID:  targetRID,
+	}
+}
+
+// SetBitrateEstimate selects the highest layer that fits into the estimate.
+func (f *SimulcastLayerFilter) SetBitrateEstimate(bitrate uint64) {
+	index := f.params.Ladder.Select(bitrate)
+	if index < 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.targetRID = f.params.Ladder[index].RID
+}
+
+// TargetRID returns the RID of the layer the filter switches to.
+func (f *SimulcastLayerFilter) TargetRID() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.targetRID
+}
+
+// CurrentSSRC returns the SSRC of the layer being forwarded.
+func (f *SimulcastLayerFilter) CurrentSSRC() (webrtc.SSRC, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.currentSSRC, f.currentRID != ""
+}
+
+func (f *SimulcastLayerFilter) WriteRTP(packet *rtp.Packet) error {
+	return f.writeRTP(packet, time.Now())
+}
+
+func (f *SimulcastLayerFilter) writeRTP(packet *rtp.Packet, now time.Time) error {
+	ssrc := webrtc.SSRC(packet.SSRC)
+
+	rid, ok := f.params.Track.LayerRID(ssrc)
+	if !ok {
+		return nil
+	}
+
+	f.mu.Lock()
+
+	forward := rid == f.currentRID
+
+	var requestKeyFrame bool
+
+	if rid != f.currentRID && rid == f.availableRID() {
+		// Only VP8 keyframes are detected, other codecs switch immediately and
+		// rely on the keyframe request.
+		if !f.isVP8 || isVP8KeyFrameStart(packet.Payload) {
+			f.switchLayer(rid, packet, now)
+
+			forward = true
+		}
+
+		if !forward || !f.isVP8 {
+			requestKeyFrame = f.keyFrameRequestAllowed(now)
+		}
+	}
+
+	var p rtp.Packet
+
+	if forward {
+		// The packet is shared between all subscribers so it must not be
+		// modified.
+		p = *packet
+		p.SequenceNumber -= f.seqOffset
+		p.Timestamp -= f.tsOffset
+
+		f.lastSeq = p.SequenceNumber
+		f.lastTimestamp = p.Timestamp
+		f.lastWrite = now
+	}
+
+	f.mu.Unlock()
+
+	if requestKeyFrame && f.params.RequestKeyFrame != nil {
+		go f.params.RequestKeyFrame(ssrc)
+	}
+
+	if !forward {
+		return nil
+	}
+
+	return errors.Trace(f.TrackLocal.WriteRTP(&p))
+}
+
+// availableRID returns the RID of the target layer, or the closest lower
+// layer that is being published. It falls back to the first layer of the
+// track when none of the ladder layers are published. The caller must hold
+// the lock.
+func (f *SimulcastLayerFilter) availableRID() string {
+	ladder := f.params.Ladder
+
+	index := -1
+
+	for i, layer := range ladder {
+		if layer.RID == f.targetRID {
+			index = i
+		}
+	}
+
+	for i := index; i >= 0; i-- {
+		if _, ok := f.params.Track.LayerSSRC(ladder[i].RID); ok {
+			return ladder[i].RID
+		}
+	}
+
+	for i := index + 1; i < len(ladder); i++ {
+		if _, ok := f.params.Track.LayerSSRC(ladder[i].RID); ok {
+			return ladder[i].RID
+		}
+	}
+
+	return f.params.Track.RID()
+}
+
+// switchLayer starts forwarding the layer with rid. The offsets are
+// calculated so that the first packet of the new layer continues the
+// sequence numbers and timestamps of the previous layer. The caller must hold
+// the lock.
+func (f *SimulcastLayerFilter) switchLayer(rid string, packet *rtp.Packet, now time.Time) {
+	if f.currentRID != "" {
+		elapsed := uint32(uint64(now.Sub(f.lastWrite)) *
+			uint64(f.params.Track.Track().Codec().ClockRate) / uint64(time.Second))
+		if elapsed == 0 {
+			elapsed = 1
+		}
+
+		f.seqOffset = packet.SequenceNumber - (f.lastSeq + 1)
+		f.tsOffset = packet.Timestamp - (f.lastTimestamp + elapsed)
+	}
+
+	f.currentRID = rid
+	f.currentSSRC = webrtc.SSRC(packet.SSRC)
+}
+
+// keyFrameRequestAllowed returns true and records the time when no keyframe
+// was requested recently. The caller must hold the lock.
+func (f *SimulcastLayerFilter) keyFrameRequestAllowed(now time.Time) bool {
+	if now.Sub(f.lastKeyFrameRequest) < simulcastKeyFrameRequestInterval {
+		return false
+	}
+
+	f.lastKeyFrameRequest = now
+
+	return true
+}
+
+// simulcastLayerTransport wraps the SimulcastTrack added to the subscriber
+// with a SimulcastLayerFilter.
+type simulcastLayerTransport struct {
+	pubsub.Transport
+
+	track           *SimulcastTrack
+	ladder          SimulcastLadder
+	requestKeyFrame func(ssrc webrtc.SSRC)
+
+	filter *SimulcastLayerFilter
+}
+
+func (t *simulcastLayerTransport) AddTrack(track transport.Track) (transport.TrackLocal, transport.RTCPReader, error) {
+	trackLocal, rtcpReader, err := t.Transport.AddTrack(track)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	t.filter = NewSimulcastLayerFilter(SimulcastLayerFilterParams{
+		TrackLocal:      trackLocal,
+		Track:           t.track,
+		Ladder:          t.ladder,
+		RequestKeyFrame: t.requestKeyFrame,
+	})
+
+	return t.filter, rtcpReader, nil
+}
@@ -0,0 +1,244 @@
+package sfu
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type simulcastLayerMock struct {
+	track   transport.SimpleTrack
+	ssrc    webrtc.SSRC
+	rid     string
+	packets chan *rtp.Packet
+}
+
+func newSimulcastLayerMock(ssrc webrtc.SSRC, rid string) *simulcastLayerMock {
+	return &simulcastLayerMock{
+		track: transport.NewSimpleTrack("track", "stream", transport.Codec{
+			MimeType:  webrtc.MimeTypeVP8,
+			ClockRate: 90000,
+		}, "peer"),
+		ssrc:    ssrc,
+		rid:     rid,
+		packets: make(chan *rtp.Packet, 16),
+	}
+}
+
+func (l *simulcastLayerMock) Track() transport.Track {
+	return l.track
+}
+
+func (l *simulcastLayerMock) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
+	packet, ok := <-l.packets
+	if !ok {
+		return nil, nil, io.EOF
+	}
+
+	return packet, nil, nil
+}
+
+func (l *simulcastLayerMock) SSRC() webrtc.SSRC {
+	return l.ssrc
+}
+
+func (l *simulcastLayerMock) RID() string {
+	return l.rid
+}
+
+var _ transport.TrackRemote = &simulcastLayerMock{}
+
+// vp8SimulcastPacket creates a single packet VP8 frame of a simulcast layer.
+func vp8SimulcastPacket(ssrc uint32, seq uint16, timestamp uint32, keyFrame bool) *rtp.Packet {
+	var frameTag byte = 0x01
+	if keyFrame {
+		frameTag = 0x00
+	}
+
+	return &rtp.Packet{
+		Header: rtp.Header{
+			SSRC:           ssrc,
+			SequenceNumber: seq,
+			Timestamp:      timestamp,
+			Marker:         true,
+		},
+		Payload: []byte{0x10, frameTag, 0x02, 0x03},
+	}
+}
+
+func TestSimulcastTrack(t *testing.T) {
+	t.Parallel()
+
+	layerQ := newSimulcastLayerMock(1, "q")
+	layerH := newSimulcastLayerMock(2, "h")
+
+	track := NewSimulcastTrack(layerQ)
+	assert.True(t, track.AddLayer(layerH))
+
+	rid, ok := track.LayerRID(2)
+	assert.True(t, ok)
+	assert.Equal(t, "h", rid)
+
+	ssrc, ok := track.LayerSSRC("q")
+	assert.True(t, ok)
+	assert.Equal(t, webrtc.SSRC(1), ssrc)
+
+	_, ok = track.LayerSSRC("f")
+	assert.False(t, ok)
+
+	assert.ElementsMatch(t, []uint32{1, 2}, track.SSRCs())
+
+	layerQ.packets <- vp8SimulcastPacket(1, 10, 100, true)
+	layerH.packets <- vp8SimulcastPacket(2, 20, 200, true)
+
+	var ssrcs []uint32
+
+	for i := 0; i < 2; i++ {
+		packet, _, err := track.ReadRTP()
+		require.NoError(t, err)
+
+		ssrcs = append(ssrcs, packet.SSRC)
+	}
+
+	assert.ElementsMatch(t, []uint32{1, 2}, ssrcs)
+
+	close(layerQ.packets)
+	close(layerH.packets)
+
+	_, _, err := track.ReadRTP()
+	assert.True(t, multierr.Is(err, io.EOF), "expected io.EOF, but got: %+v", err)
+
+	assert.False(t, track.AddLayer(newSimulcastLayerMock(3, "f")), "should not add layers to an ended track")
+}
+
+func TestSimulcastTrack_Close(t *testing.T) {
+	t.Parallel()
+
+	layerQ := newSimulcastLayerMock(1, "q")
+	layerH := newSimulcastLayerMock(2, "h")
+
+	track := NewSimulcastTrack(layerQ)
+	assert.True(t, track.AddLayer(layerH))
+
+	// Nobody reads the packets of the track, so the layers block until the
+	// track is closed.
+	layerQ.packets <- vp8SimulcastPacket(1, 10, 100, true)
+	layerH.packets <- vp8SimulcastPacket(2, 20, 200, true)
+
+	track.Close()
+	track.Close()
+
+	_, _, err := track.ReadRTP()
+	assert.True(t, multierr.Is(err, io.EOF), "expected io.EOF, but got: %+v", err)
+
+	assert.False(t, track.AddLayer(newSimulcastLayerMock(3, "f")), "should not add layers to a closed track")
+
+	close(layerQ.packets)
+	close(layerH.packets)
+
+	select {
+	case <-track.done:
+	case <-time.After(time.Second):
+		assert.Fail(t, "timed out waiting for the layers to end")
+	}
+}
+
+func TestSimulcastLayerFilter(t *testing.T) {
+	t.Parallel()
+
+	layerQ := newSimulcastLayerMock(1, "q")
+	layerH := newSimulcastLayerMock(2, "h")
+
+	track := NewSimulcastTrack(layerQ)
+	assert.True(t, track.AddLayer(layerH))
+
+	defer func() {
+		close(layerQ.packets)
+		close(layerH.packets)
+	}()
+
+	keyFrameRequests := make(chan webrtc.SSRC, 4)
+
+	trackLocal := &mockTrackLocal{}
+
+	filter := NewSimulcastLayerFilter(SimulcastLayerFilterParams{
+		TrackLocal: trackLocal,
+		Track:      track,
+		Ladder: SimulcastLadder{
+			{RID: "q", ScaleResolutionDownBy: 2, MaxBitrate: 100},
+			{RID: "h", ScaleResolutionDownBy: 1, MaxBitrate: 300},
+		},
+		RequestKeyFrame: func(ssrc webrtc.SSRC) {
+			keyFrameRequests <- ssrc
+		},
+	})
+
+	now := time.Unix(1000, 0)
+
+	assert.Equal(t, "q", filter.TargetRID())
+
+	_, ok := filter.CurrentSSRC()
+	assert.False(t, ok)
+
+	// The lowest layer is selected by default and the filter waits for its
+	// keyframe.
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(2, 500, 9000, true), now))
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(1, 100, 3000, false), now))
+	assert.Empty(t, trackLocal.packets)
+	assert.Equal(t, webrtc.SSRC(1), <-keyFrameRequests)
+
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(1, 101, 6000, true), now))
+
+	ssrc, ok := filter.CurrentSSRC()
+	assert.True(t, ok)
+	assert.Equal(t, webrtc.SSRC(1), ssrc)
+
+	filter.SetBitrateEstimate(1000)
+	assert.Equal(t, "h", filter.TargetRID())
+
+	// The current layer is forwarded until a keyframe of the target layer
+	// arrives.
+	now = now.Add(time.Second)
+
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(2, 501, 18000, false), now))
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(1, 102, 15000, false), now))
+	assert.Equal(t, webrtc.SSRC(2), <-keyFrameRequests)
+
+	now = now.Add(100 * time.Millisecond)
+
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(2, 502, 27000, true), now))
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(1, 103, 24000, false), now))
+	require.NoError(t, filter.writeRTP(vp8SimulcastPacket(2, 503, 36000, false), now.Add(100*time.Millisecond)))
+
+	ssrc, _ = filter.CurrentSSRC()
+	assert.Equal(t, webrtc.SSRC(2), ssrc)
+
+	type written struct {
+		ssrc      uint32
+		seq       uint16
+		timestamp uint32
+	}
+
+	var got []written
+
+	for _, p := range trackLocal.packets {
+		got = append(got, written{p.SSRC, p.SequenceNumber, p.Timestamp})
+	}
+
+	assert.Equal(t, []written{
+		{1, 101, 6000},
+		{1, 102, 15000},
+		// The timestamp continues with the 100ms that elapsed since the previous
+		// packet.
+		{2, 103, 24000},
+		{2, 104, 33000},
+	}, got)
+}
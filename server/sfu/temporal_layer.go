@@ -0,0 +1,220 @@
+package sfu
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/pubsub"
+	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// maxTemporalLayer is the highest TID that fits into the 2-bit field of
+	// the VP8 payload descriptor.
+	maxTemporalLayer        = 3
+	temporalLayerRateWindow = time.Second
+)
+
+// TemporalLayerFilter forwards only the VP8 temporal layers that fit into the
+// estimated bitrate of a single subscriber. Since the upper temporal layers
+// are not referenced by the lower ones, dropping them lowers the frame rate
+// and bitrate without requiring simulcast from the publisher. Sequence
+// numbers are rewritten so that the subscriber does not consider the dropped
+// packets lost, and PictureIDs are rewritten so that the subscriber does not
+// consider the dropped frames lost. TL0PICIDX does not need to be rewritten
+// because base layer frames are never dropped.
+type TemporalLayerFilter struct {
+	transport.TrackLocal
+
+	mu sync.Mutex
+
+	// targetLayer is the highest layer that fits into the bitrate estimate.
+	targetLayer uint8
+	// currentLayer is the highest layer being forwarded. It only changes on
+	// frame boundaries.
+	currentLayer uint8
+	// dropping is true while the packets of a dropped frame are being written.
+	dropping   bool
+	numDropped uint16
+	// numDroppedFrames is the number of dropped frames, by which the PictureIDs
+	// are decreased.
+	numDroppedFrames uint16
+	// lastTimestamp is the RTP timestamp of the last frame. A new timestamp
+	// starts a new frame even when its first packet was lost.
+	lastTimestamp uint32
+
+	hasTemporalLayers bool
+
+	windowStart time.Time
+	// windowBytes contains the number of bytes received for each layer in the
+	// current window.
+	windowBytes [maxTemporalLayer + 1]uint64
+	// bitrates contains the bitrate of each layer in the last complete window.
+	bitrates [maxTemporalLayer + 1]uint64
+}
+
+var _ transport.TrackLocal = &TemporalLayerFilter{}
+
+// NewTemporalLayerFilter creates a new instance of TemporalLayerFilter. All
+// layers are forwarded until a bitrate estimate is set.
+func NewTemporalLayerFilter(trackLocal transport.TrackLocal) *TemporalLayerFilter {
+	return &TemporalLayerFilter{
+		TrackLocal:   trackLocal,
+		targetLayer:  maxTemporalLayer,
+		currentLayer: maxTemporalLayer,
+	}
+}
+
+// HasTemporalLayers returns true after a packet with a temporal layer index
+// has been written.
+func (f *TemporalLayerFilter) HasTemporalLayers() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.hasTemporalLayers
+}
+
+// TargetLayer returns the highest temporal layer that will be forwarded.
+func (f *TemporalLayerFilter) TargetLayer() uint8 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.targetLayer
+}
+
+// SetBitrateEstimate selects the highest temporal layer whose cumulative
+// bitrate fits into the estimate. The base layer is always forwarded.
+func (f *TemporalLayerFilter) SetBitrateEstimate(bitrate uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var (
+		target     uint8
+		cumulative uint64
+	)
+
+	for layer := uint8(0); layer <= maxTemporalLayer; layer++ {
+		cumulative += f.bitrates[layer]
+
+		if cumulative <= bitrate {
+			target = layer
+		}
+	}
+
+	f.targetLayer = target
+}
+
+func (f *TemporalLayerFilter) WriteRTP(packet *rtp.Packet) error {
+	return f.writeRTP(packet, time.Now())
+}
+
+func (f *TemporalLayerFilter) writeRTP(packet *rtp.Packet, now time.Time) error {
+	descriptor, err := parseVP8Descriptor(packet.Payload)
+
+	f.mu.Lock()
+
+	var drop bool
+
+	if err == nil && descriptor.HasTID {
+		f.hasTemporalLayers = true
+		f.measure(descriptor.TID, packet.MarshalSize(), now)
+
+		if descriptor.FrameStart || packet.Timestamp != f.lastTimestamp {
+			f.maybeSwitchLayer(descriptor)
+			f.dropping = descriptor.TID > f.currentLayer
+
+			if f.dropping {
+				f.numDroppedFrames++
+			}
+		}
+
+		f.lastTimestamp = packet.Timestamp
+
+		drop = f.dropping
+	}
+
+	if drop {
+		f.numDropped++
+	}
+
+	numDropped := f.numDropped
+	numDroppedFrames := f.numDroppedFrames
+
+	f.mu.Unlock()
+
+	if drop {
+		return nil
+	}
+
+	if numDropped == 0 && numDroppedFrames == 0 {
+		return errors.Trace(f.TrackLocal.WriteRTP(packet))
+	}
+
+	// The packet is shared between all subscribers so it must not be modified.
+	p := *packet
+	p.SequenceNumber -= numDropped
+
+	if err == nil && descriptor.HasPictureID && numDroppedFrames > 0 {
+		p.Payload = rewriteVP8PictureID(packet.Payload, descriptor, descriptor.PictureID-numDroppedFrames)
+	}
+
+	return errors.Trace(f.TrackLocal.WriteRTP(&p))
+}
+
+// maybeSwitchLayer switches down immediately, but switches up only on base
+// layer frames, since the upper layer frames might reference frames that
+// have been dropped. The caller must hold the lock.
+func (f *TemporalLayerFilter) maybeSwitchLayer(descriptor vp8Descriptor) {
+	switch {
+	case f.targetLayer < f.currentLayer:
+		f.currentLayer = f.targetLayer
+	case f.targetLayer > f.currentLayer && descriptor.TID == 0:
+		f.currentLayer = f.targetLayer
+	}
+}
+
+// measure records the size of the packet in the layer bitrate window. The
+// caller must hold the lock.
+func (f *TemporalLayerFilter) measure(tid uint8, size int, now time.Time) {
+	if f.windowStart.IsZero() {
+		f.windowStart = now
+	}
+
+	if elapsed := now.Sub(f.windowStart); elapsed >= temporalLayerRateWindow {
+		for layer, bytes := range f.windowBytes {
+			f.bitrates[layer] = bytes * 8 * uint64(time.Second) / uint64(elapsed)
+			f.windowBytes[layer] = 0
+		}
+
+		f.windowStart = now
+	}
+
+	f.windowBytes[tid] += uint64(size)
+}
+
+// temporalLayerTransport wraps the VP8 tracks added to the subscriber with a
+// TemporalLayerFilter.
+type temporalLayerTransport struct {
+	pubsub.Transport
+
+	filter *TemporalLayerFilter
+}
+
+func (t *temporalLayerTransport) AddTrack(track transport.Track) (transport.TrackLocal, transport.RTCPReader, error) {
+	trackLocal, rtcpReader, err := t.Transport.AddTrack(track)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+
+	if !strings.EqualFold(track.Codec().MimeType, webrtc.MimeTypeVP8) {
+		return trackLocal, rtcpReader, nil
+	}
+
+	t.filter = NewTemporalLayerFilter(trackLocal)
+
+	return t.filter, rtcpReader, nil
+}
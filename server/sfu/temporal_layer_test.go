@@ -0,0 +1,326 @@
+package sfu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/multierr"
+	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTrackLocal struct {
+	transport.TrackLocal
+
+	packets []rtp.Packet
+}
+
+func (m *mockTrackLocal) WriteRTP(packet *rtp.Packet) error {
+	m.packets = append(m.packets, *packet)
+
+	return nil
+}
+
+// vp8TemporalPacket creates a single packet VP8 frame with a temporal layer
+// index.
+func vp8TemporalPacket(seq uint16, tid uint8) *rtp.Packet {
+	return &rtp.Packet{
+		Header: rtp.Header{
+			SequenceNumber: seq,
+			Marker:         true,
+		},
+		Payload: []byte{0x90, 0x20, tid << 6, 0x01, 0x02, 0x03},
+	}
+}
+
+func TestParseVP8Descriptor(t *testing.T) {
+	t.Parallel()
+
+	d, err := parseVP8Descriptor([]byte{0x10, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, vp8Descriptor{FrameStart: true}, d)
+
+	// PictureID (15-bit), TL0PICIDX and TID present.
+	d, err = parseVP8Descriptor([]byte{0x90, 0xE0, 0x81, 0x02, 0x05, 0xA0, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, vp8Descriptor{
+		FrameStart:     true,
+		HasTID:         true,
+		TID:            2,
+		LayerSync:      true,
+		HasPictureID:   true,
+		PictureID:      0x0102,
+		PictureIDIndex: 2,
+		LongPictureID:  true,
+	}, d)
+
+	// PictureID (7-bit) and TID present.
+	d, err = parseVP8Descriptor([]byte{0x90, 0xA0, 0x05, 0x40, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, vp8Descriptor{
+		FrameStart:     true,
+		HasTID:         true,
+		TID:            1,
+		HasPictureID:   true,
+		PictureID:      5,
+		PictureIDIndex: 2,
+	}, d)
+
+	// Continuation packet.
+	d, err = parseVP8Descriptor([]byte{0x80, 0x20, 0x40, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, vp8Descriptor{HasTID: true, TID: 1}, d)
+
+	for _, payload := range [][]byte{
+		{},
+		{0x80},
+		{0x80, 0x80},
+		{0x80, 0x20},
+		{0x80, 0x80, 0x81},
+	} {
+		_, err := parseVP8Descriptor(payload)
+		assert.True(t, multierr.Is(err, errShortVP8Descriptor), "payload: %v", payload)
+	}
+}
+
+func TestTemporalLayerFilter(t *testing.T) {
+	t.Parallel()
+
+	trackLocal := &mockTrackLocal{}
+	filter := NewTemporalLayerFilter(trackLocal)
+
+	// L1T3 pattern.
+	tids := []uint8{0, 2, 1, 2}
+	now := time.Now()
+
+	var seq uint16 = 65530
+
+	write := func(numFrames int) {
+		for i := 0; i < numFrames; i++ {
+			err := filter.writeRTP(vp8TemporalPacket(seq, tids[int(seq)%len(tids)]), now)
+			require.NoError(t, err)
+
+			seq++
+			now = now.Add(time.Second / 32)
+		}
+	}
+
+	// No measurements yet, all layers should be forwarded.
+	filter.SetBitrateEstimate(0)
+	assert.Equal(t, uint8(maxTemporalLayer), filter.TargetLayer())
+
+	write(36)
+	assert.True(t, filter.HasTemporalLayers())
+	assert.Len(t, trackLocal.packets, 36)
+
+	// Layers 0 and 1 each contain a quarter of the packets.
+	size := uint64(vp8TemporalPacket(0, 0).MarshalSize())
+	halfBitrate := size * 8 * 16
+
+	filter.SetBitrateEstimate(halfBitrate)
+	assert.Equal(t, uint8(1), filter.TargetLayer())
+
+	trackLocal.packets = nil
+
+	write(8)
+
+	require.Len(t, trackLocal.packets, 4)
+
+	for i, packet := range trackLocal.packets {
+		d, err := parseVP8Descriptor(packet.Payload)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, d.TID, uint8(1))
+
+		if i > 0 {
+			assert.Equal(t, trackLocal.packets[i-1].SequenceNumber+1, packet.SequenceNumber)
+		}
+	}
+
+	filter.SetBitrateEstimate(0)
+	assert.Equal(t, uint8(0), filter.TargetLayer())
+
+	// Upgrade should only happen on a base layer frame.
+	filter.SetBitrateEstimate(halfBitrate * 4)
+	assert.Equal(t, uint8(maxTemporalLayer), filter.TargetLayer())
+
+	// Skip to the layer 2 frame which is followed by a layer 1 frame.
+	for int(seq)%len(tids) != 1 {
+		write(1)
+	}
+
+	trackLocal.packets = nil
+
+	filter.SetBitrateEstimate(0)
+	write(1)
+	assert.Empty(t, trackLocal.packets, "should drop layer 2 frame")
+
+	filter.SetBitrateEstimate(halfBitrate * 4)
+	write(2)
+	assert.Empty(t, trackLocal.packets, "should not upgrade before base layer frame")
+
+	write(1)
+	require.Len(t, trackLocal.packets, 1, "should upgrade on base layer frame")
+
+	lastSeq := trackLocal.packets[0].SequenceNumber
+
+	write(1)
+	require.Len(t, trackLocal.packets, 2)
+	assert.Equal(t, lastSeq+1, trackLocal.packets[1].SequenceNumber)
+}
+
+func TestTemporalLayerFilter_lostFrameStart(t *testing.T) {
+	t.Parallel()
+
+	trackLocal := &mockTrackLocal{}
+	filter := NewTemporalLayerFilter(trackLocal)
+
+	filter.targetLayer = 0
+	filter.currentLayer = 0
+
+	// continuation creates a packet that is not the first packet of a frame.
+	continuation := func(seq uint16, tid uint8, timestamp uint32) *rtp.Packet {
+		packet := vp8TemporalPacket(seq, tid)
+		packet.Timestamp = timestamp
+		packet.Payload[0] = 0x80
+
+		return packet
+	}
+
+	frameStart := vp8TemporalPacket(1, 0)
+	frameStart.Timestamp = 1000
+
+	now := time.Now()
+
+	require.NoError(t, filter.writeRTP(frameStart, now))
+	require.NoError(t, filter.writeRTP(continuation(2, 0, 1000), now))
+	// The first packet of the layer 2 frame was lost.
+	require.NoError(t, filter.writeRTP(continuation(4, 2, 2000), now))
+	require.NoError(t, filter.writeRTP(continuation(5, 2, 2000), now))
+	// The first packet of the layer 0 frame was lost.
+	require.NoError(t, filter.writeRTP(continuation(7, 0, 3000), now))
+
+	var seqs []uint16
+
+	for _, packet := range trackLocal.packets {
+		seqs = append(seqs, packet.SequenceNumber)
+	}
+
+	assert.Equal(t, []uint16{1, 2, 5}, seqs)
+}
+
+func TestTemporalLayerFilter_noTemporalLayers(t *testing.T) {
+	t.Parallel()
+
+	trackLocal := &mockTrackLocal{}
+	filter := NewTemporalLayerFilter(trackLocal)
+
+	packet := &rtp.Packet{
+		Header:  rtp.Header{SequenceNumber: 10},
+		Payload: []byte{0x10, 0x00},
+	}
+
+	require.NoError(t, filter.WriteRTP(packet))
+	filter.SetBitrateEstimate(0)
+	require.NoError(t, filter.WriteRTP(packet))
+
+	assert.False(t, filter.HasTemporalLayers())
+	assert.Equal(t, []rtp.Packet{*packet, *packet}, trackLocal.packets)
+}
+
+func TestTemporalLayerFilter_pictureID(t *testing.T) {
+	t.Parallel()
+
+	// vp8PicturePacket creates a single packet VP8 frame with a PictureID and
+	// a temporal layer index.
+	vp8PicturePacket := func(seq uint16, tid uint8, pictureID uint16, long bool) *rtp.Packet {
+		payload := []byte{0x90, 0xA0}
+
+		if long {
+			payload = append(payload, 0x80|byte(pictureID>>8), byte(pictureID))
+		} else {
+			payload = append(payload, byte(pictureID&0x7F))
+		}
+
+		payload = append(payload, tid<<6, 0x01, 0x02, 0x03)
+
+		return &rtp.Packet{
+			Header: rtp.Header{
+				SequenceNumber: seq,
+				Timestamp:      uint32(seq) * 3000,
+				Marker:         true,
+			},
+			Payload: payload,
+		}
+	}
+
+	type testCase struct {
+		long           bool
+		mask           uint16
+		firstPictureID uint16
+		wantPictureIDs []uint16
+	}
+
+	testCases := map[string]testCase{
+		"7-bit": {
+			long:           false,
+			mask:           0x7F,
+			firstPictureID: 0x7D,
+			wantPictureIDs: []uint16{0x7D, 0x7E, 0x7F, 0x00},
+		},
+		"15-bit": {
+			long:           true,
+			mask:           0x7FFF,
+			firstPictureID: 0x7FFD,
+			wantPictureIDs: []uint16{0x7FFD, 0x7FFE, 0x7FFF, 0x0000},
+		},
+	}
+
+	for name, tc := range testCases {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			trackLocal := &mockTrackLocal{}
+			filter := NewTemporalLayerFilter(trackLocal)
+
+			filter.targetLayer = 1
+			filter.currentLayer = 1
+
+			// L1T3 pattern, layer 2 frames are dropped.
+			tids := []uint8{0, 2, 1, 2, 0, 2, 1}
+			now := time.Now()
+
+			var published []*rtp.Packet
+
+			for i, tid := range tids {
+				packet := vp8PicturePacket(uint16(i), tid, tc.firstPictureID+uint16(i), tc.long)
+				published = append(published, packet)
+
+				require.NoError(t, filter.writeRTP(packet, now))
+			}
+
+			var pictureIDs []uint16
+
+			for i, packet := range trackLocal.packets {
+				d, err := parseVP8Descriptor(packet.Payload)
+				require.NoError(t, err)
+
+				assert.Equal(t, uint16(i), packet.SequenceNumber)
+				assert.Equal(t, tc.long, d.LongPictureID)
+
+				pictureIDs = append(pictureIDs, d.PictureID)
+			}
+
+			assert.Equal(t, tc.wantPictureIDs, pictureIDs)
+
+			for i, packet := range published {
+				d, err := parseVP8Descriptor(packet.Payload)
+				require.NoError(t, err)
+				assert.Equal(t, (tc.firstPictureID+uint16(i))&tc.mask, d.PictureID, "should not modify published packet")
+			}
+		})
+	}
+}
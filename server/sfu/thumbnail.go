@@ -0,0 +1,322 @@
+package sfu
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/atomic"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/logger"
+	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	DefaultThumbnailInterval = 10 * time.Second
+	DefaultThumbnailQuality  = 60
+	// DefaultThumbnailKeyFrameGracePeriod is how long to wait for a keyframe
+	// sent by the publisher before requesting one.
+	DefaultThumbnailKeyFrameGracePeriod = 3 * time.Second
+)
+
+// KeyFrameDecoder decodes a single complete keyframe into an image.
+type KeyFrameDecoder interface {
+	DecodeKeyFrame(frame []byte) (image.Image, error)
+}
+
+type ThumbnailerParams struct {
+	Log logger.Logger
+	// Interval is the minimum duration between two thumbnails of the same
+	// track.
+	Interval time.Duration
+	// KeyFrameGracePeriod is how long to wait for a keyframe after the
+	// interval has elapsed before one is requested from the publisher.
+	KeyFrameGracePeriod time.Duration
+	// Quality is the JPEG quality, from 1 to 100.
+	Quality int
+	// Decoders contains the keyframe decoders indexed by mime type. Tracks with
+	// other mime types will not have thumbnails. Only VP8 keyframes can be
+	// reassembled for now. Defaults to DefaultKeyFrameDecoders.
+	Decoders map[string]KeyFrameDecoder
+}
+
+// Thumbnail is the last decoded keyframe of a video track, encoded as JPEG.
+type Thumbnail struct {
+	ClientID  identifiers.ClientID `json:"clientId"`
+	TrackID   identifiers.TrackID  `json:"trackId"`
+	Timestamp time.Time            `json:"timestamp"`
+	Width     int                  `json:"width"`
+	Height    int                  `json:"height"`
+	JPEG      []byte               `json:"-"`
+}
+
+// Thumbnailer periodically decodes keyframes of published video tracks so
+// they can be previewed without a WebRTC subscription.
+type Thumbnailer struct {
+	params *ThumbnailerParams
+
+	mu sync.RWMutex
+	// thumbnails contains an entry for each track returned from Track. The
+	// JPEG is nil until the first keyframe has been decoded.
+	thumbnails map[identifiers.RoomID]map[identifiers.TrackID]Thumbnail
+}
+
+func NewThumbnailer(params ThumbnailerParams) *Thumbnailer {
+	params.Log = params.Log.WithNamespaceAppended("thumbnailer")
+
+	if params.Interval == 0 {
+		params.Interval = DefaultThumbnailInterval
+	}
+
+	if params.Quality == 0 {
+		params.Quality = DefaultThumbnailQuality
+	}
+
+	if params.KeyFrameGracePeriod == 0 {
+		params.KeyFrameGracePeriod = DefaultThumbnailKeyFrameGracePeriod
+	}
+
+	if params.Decoders == nil {
+		params.Decoders = DefaultKeyFrameDecoders()
+	}
+
+	return &Thumbnailer{
+		params:     &params,
+		thumbnails: map[identifiers.RoomID]map[identifiers.TrackID]Thumbnail{},
+	}
+}
+
+// Track returns a track that feeds the read packets to the Thumbnailer. The
+// original track is returned when there is no decoder for its codec.
+// requestKeyFrame is called when no keyframe has been received during the
+// grace period after the interval has elapsed. It should be rate limited
+// together with the other keyframe requests sent to the publisher.
+func (t *Thumbnailer) Track(
+	room identifiers.RoomID,
+	clientID identifiers.ClientID,
+	track transport.TrackRemote,
+	requestKeyFrame func(),
+) transport.TrackRemote {
+	mimeType := track.Track().Codec().MimeType
+
+	if !strings.EqualFold(mimeType, webrtc.MimeTypeVP8) {
+		return track
+	}
+
+	decoder, ok := t.decoder(mimeType)
+	if !ok {
+		return track
+	}
+
+	trackID := track.Track().TrackID()
+
+	t.mu.Lock()
+
+	thumbnails, ok := t.thumbnails[room]
+	if !ok {
+		thumbnails = map[identifiers.TrackID]Thumbnail{}
+		t.thumbnails[room] = thumbnails
+	}
+
+	thumbnails[trackID] = Thumbnail{
+		ClientID: clientID,
+		TrackID:  trackID,
+	}
+
+	t.mu.Unlock()
+
+	return &thumbnailTrack{
+		TrackRemote:     track,
+		thumbnailer:     t,
+		decoder:         decoder,
+		room:            room,
+		clientID:        clientID,
+		requestKeyFrame: requestKeyFrame,
+		log: t.params.Log.WithCtx(logger.Ctx{
+			"room_id":   room,
+			"client_id": clientID,
+			"track_id":  trackID,
+		}),
+	}
+}
+
+func (t *Thumbnailer) decoder(mimeType string) (KeyFrameDecoder, bool) {
+	for key, decoder := range t.params.Decoders {
+		if strings.EqualFold(key, mimeType) {
+			return decoder, true
+		}
+	}
+
+	return nil, false
+}
+
+// Thumbnail returns the last thumbnail of a track.
+func (t *Thumbnailer) Thumbnail(room identifiers.RoomID, trackID identifiers.TrackID) (Thumbnail, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	thumbnail, ok := t.thumbnails[room][trackID]
+
+	return thumbnail, ok && thumbnail.JPEG != nil
+}
+
+// Thumbnails returns the last thumbnails of all tracks in the room.
+func (t *Thumbnailer) Thumbnails(room identifiers.RoomID) []Thumbnail {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	thumbnails := make([]Thumbnail, 0, len(t.thumbnails[room]))
+
+	for _, thumbnail := range t.thumbnails[room] {
+		if thumbnail.JPEG != nil {
+			thumbnails = append(thumbnails, thumbnail)
+		}
+	}
+
+	return thumbnails
+}
+
+// Remove removes the thumbnail of an unpublished track. Keyframes that are
+// still being decoded will be discarded.
+func (t *Thumbnailer) Remove(room identifiers.RoomID, trackID identifiers.TrackID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.thumbnails[room], trackID)
+
+	if len(t.thumbnails[room]) == 0 {
+		delete(t.thumbnails, room)
+	}
+}
+
+func (t *Thumbnailer) set(room identifiers.RoomID, thumbnail Thumbnail) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.thumbnails[room][thumbnail.TrackID]; ok {
+		t.thumbnails[room][thumbnail.TrackID] = thumbnail
+	}
+}
+
+func (t *Thumbnailer) encode(img image.Image) ([]byte, error) {
+	var b bytes.Buffer
+
+	err := jpeg.Encode(&b, img, &jpeg.Options{
+		Quality: t.params.Quality,
+	})
+	if err != nil {
+		return nil, errors.Annotate(err, "encode jpeg")
+	}
+
+	return b.Bytes(), nil
+}
+
+type thumbnailTrack struct {
+	transport.TrackRemote
+
+	thumbnailer *Thumbnailer
+	decoder     KeyFrameDecoder
+	log         logger.Logger
+
+	room            identifiers.RoomID
+	clientID        identifiers.ClientID
+	requestKeyFrame func()
+
+	assembler vp8KeyFrameAssembler
+
+	// lastThumbnail is the time the last keyframe was taken for decoding.
+	lastThumbnail time.Time
+	// lastRequest is the time of the last keyframe request.
+	lastRequest time.Time
+	// waitingSince is the time the first packet was read after the interval
+	// has elapsed.
+	waitingSince time.Time
+	// decoding prevents decoding more than one keyframe at a time.
+	decoding atomic.Bool
+}
+
+func (t *thumbnailTrack) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
+	packet, attributes, err := t.TrackRemote.ReadRTP()
+	if err != nil {
+		return packet, attributes, err
+	}
+
+	t.handlePacket(packet, time.Now())
+
+	return packet, attributes, nil
+}
+
+func (t *thumbnailTrack) handlePacket(packet *rtp.Packet, now time.Time) {
+	params := t.thumbnailer.params
+
+	if now.Sub(t.lastThumbnail) < params.Interval {
+		return
+	}
+
+	if t.waitingSince.IsZero() {
+		t.waitingSince = now
+	}
+
+	frame, ok := t.assembler.Push(packet)
+	if !ok {
+		// Give the publisher a chance to send a keyframe on its own, since a
+		// requested keyframe is forwarded to all subscribers too.
+		if now.Sub(t.waitingSince) >= params.KeyFrameGracePeriod &&
+			now.Sub(t.lastRequest) >= params.Interval {
+			t.lastRequest = now
+			t.requestKeyFrame()
+		}
+
+		return
+	}
+
+	if !t.decoding.CompareAndSwap(true) {
+		return
+	}
+
+	t.lastThumbnail = now
+	t.waitingSince = time.Time{}
+
+	// The frame buffer is reused by the assembler.
+	frame = append([]byte(nil), frame...)
+
+	go func() {
+		defer t.decoding.Set(false)
+
+		if err := t.decode(frame, now); err != nil {
+			t.log.Error("Decode thumbnail", errors.Trace(err), nil)
+		}
+	}()
+}
+
+func (t *thumbnailTrack) decode(frame []byte, timestamp time.Time) error {
+	img, err := t.decoder.DecodeKeyFrame(frame)
+	if err != nil {
+		return errors.Annotate(err, "decode keyframe")
+	}
+
+	b, err := t.thumbnailer.encode(img)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	bounds := img.Bounds()
+
+	t.thumbnailer.set(t.room, Thumbnail{
+		ClientID:  t.clientID,
+		TrackID:   t.Track().TrackID(),
+		Timestamp: timestamp,
+		Width:     bounds.Dx(),
+		Height:    bounds.Dy(),
+		JPEG:      b,
+	})
+
+	return nil
+}
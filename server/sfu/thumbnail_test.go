@@ -0,0 +1,332 @@
+package sfu_test
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/sfu"
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type trackRemoteMock struct {
+	track   transport.SimpleTrack
+	packets []*rtp.Packet
+}
+
+func (t *trackRemoteMock) Track() transport.Track {
+	return t.track
+}
+
+func (t *trackRemoteMock) ReadRTP() (*rtp.Packet, interceptor.Attributes, error) {
+	if len(t.packets) == 0 {
+		return nil, nil, io.EOF
+	}
+
+	packet := t.packets[0]
+	t.packets = t.packets[1:]
+
+	return packet, nil, nil
+}
+
+func (t *trackRemoteMock) SSRC() webrtc.SSRC {
+	return webrtc.SSRC(1234)
+}
+
+func (t *trackRemoteMock) RID() string {
+	return ""
+}
+
+var _ transport.TrackRemote = &trackRemoteMock{}
+
+type keyFrameDecoderMock struct {
+	frames chan []byte
+}
+
+func (d *keyFrameDecoderMock) DecodeKeyFrame(frame []byte) (image.Image, error) {
+	d.frames <- frame
+
+	return image.NewRGBA(image.Rect(0, 0, 4, 2)), nil
+}
+
+func newVP8Packet(seq uint16, start, keyFrame, marker bool, data byte) *rtp.Packet {
+	var descriptor byte
+	if start {
+		descriptor = 0x10
+	}
+
+	var frameTag byte = 0x01
+	if keyFrame {
+		frameTag = 0x00
+	}
+
+	return &rtp.Packet{
+		Header: rtp.Header{
+			SequenceNumber: seq,
+			Marker:         marker,
+		},
+		Payload: []byte{descriptor, frameTag, data, data, data},
+	}
+}
+
+func TestThumbnailer(t *testing.T) {
+	t.Parallel()
+
+	decoder := &keyFrameDecoderMock{
+		frames: make(chan []byte, 1),
+	}
+
+	thumbnailer := sfu.NewThumbnailer(sfu.ThumbnailerParams{
+		Log:      test.NewLogger(),
+		Interval: time.Hour,
+		Quality:  0,
+		// Request a keyframe as soon as possible.
+		KeyFrameGracePeriod: time.Nanosecond,
+		Decoders: map[string]sfu.KeyFrameDecoder{
+			webrtc.MimeTypeVP8: decoder,
+		},
+	})
+
+	codec := transport.Codec{
+		MimeType:  webrtc.MimeTypeVP8,
+		ClockRate: 90000,
+	}
+
+	mock := &trackRemoteMock{
+		track: transport.NewSimpleTrack("track1", "stream1", codec, "a"),
+		packets: []*rtp.Packet{
+			// Delta frame.
+			newVP8Packet(1, true, false, true, 1),
+			// Keyframe with missing packets.
+			newVP8Packet(2, true, true, false, 2),
+			newVP8Packet(4, false, false, true, 2),
+			// Complete keyframe.
+			newVP8Packet(5, true, true, false, 3),
+			newVP8Packet(6, false, false, true, 4),
+			// Next keyframe is too soon.
+			newVP8Packet(7, true, true, true, 5),
+		},
+	}
+
+	var numKeyFrameRequests int
+
+	track := thumbnailer.Track("room1", "a", mock, func() {
+		numKeyFrameRequests++
+	})
+
+	trackID := identifiers.TrackID{ID: "track1", StreamID: "stream1"}
+
+	_, ok := thumbnailer.Thumbnail("room1", trackID)
+	assert.False(t, ok)
+
+	for {
+		if _, _, err := track.ReadRTP(); err != nil {
+			require.Equal(t, io.EOF, err)
+
+			break
+		}
+	}
+
+	assert.Equal(t, 1, numKeyFrameRequests)
+
+	select {
+	case frame := <-decoder.frames:
+		assert.Equal(t, []byte{0x00, 3, 3, 3, 0x01, 4, 4, 4}, frame)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for keyframe")
+	}
+
+	var thumbnail sfu.Thumbnail
+
+	assert.Eventually(t, func() bool {
+		thumbnail, ok = thumbnailer.Thumbnail("room1", trackID)
+
+		return ok
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, identifiers.ClientID("a"), thumbnail.ClientID)
+	assert.Equal(t, 4, thumbnail.Width)
+	assert.Equal(t, 2, thumbnail.Height)
+
+	img, err := jpeg.Decode(bytes.NewReader(thumbnail.JPEG))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 4, 2), img.Bounds())
+
+	assert.Len(t, thumbnailer.Thumbnails("room1"), 1)
+
+	thumbnailer.Remove("room1", trackID)
+
+	_, ok = thumbnailer.Thumbnail("room1", trackID)
+	assert.False(t, ok)
+	assert.Empty(t, thumbnailer.Thumbnails("room1"))
+}
+
+func TestThumbnailer_unsupportedCodec(t *testing.T) {
+	t.Parallel()
+
+	thumbnailer := sfu.NewThumbnailer(sfu.ThumbnailerParams{
+		Log:      test.NewLogger(),
+		Interval: time.Second,
+		Quality:  0,
+		Decoders: nil,
+	})
+
+	mock := &trackRemoteMock{
+		track: transport.NewSimpleTrack("track1", "stream1", transport.Codec{
+			MimeType:  webrtc.MimeTypeH264,
+			ClockRate: 90000,
+		}, "a"),
+	}
+
+	track := thumbnailer.Track("room1", "a", mock, func() {})
+	assert.Equal(t, transport.TrackRemote(mock), track)
+}
+
+func TestThumbnailer_keyFrameGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	thumbnailer := sfu.NewThumbnailer(sfu.ThumbnailerParams{
+		Log:      test.NewLogger(),
+		Interval: time.Hour,
+		Quality:  0,
+		Decoders: map[string]sfu.KeyFrameDecoder{
+			webrtc.MimeTypeVP8: &keyFrameDecoderMock{
+				frames: make(chan []byte, 1),
+			},
+		},
+	})
+
+	mock := &trackRemoteMock{
+		track: transport.NewSimpleTrack("track1", "stream1", transport.Codec{
+			MimeType:  webrtc.MimeTypeVP8,
+			ClockRate: 90000,
+		}, "a"),
+	}
+
+	for i := 0; i < 10; i++ {
+		mock.packets = append(mock.packets, newVP8Packet(uint16(i), true, false, true, 1))
+	}
+
+	var numKeyFrameRequests int
+
+	track := thumbnailer.Track("room1", "a", mock, func() {
+		numKeyFrameRequests++
+	})
+
+	for {
+		if _, _, err := track.ReadRTP(); err != nil {
+			require.Equal(t, io.EOF, err)
+
+			break
+		}
+	}
+
+	assert.Equal(t, 0, numKeyFrameRequests, "should wait for a keyframe from the publisher first")
+}
+
+func TestThumbnailer_defaultDecoder(t *testing.T) {
+	t.Parallel()
+
+	// The keyframe has been extracted from a lossy WebP image from the
+	// golang.org/x/image test data.
+	keyFrame, err := ioutil.ReadFile("testdata/keyframe.vp8")
+	require.NoError(t, err)
+
+	thumbnailer := sfu.NewThumbnailer(sfu.ThumbnailerParams{
+		Log:      test.NewLogger(),
+		Interval: time.Hour,
+		Quality:  0,
+		Decoders: nil,
+	})
+
+	mock := &trackRemoteMock{
+		track: transport.NewSimpleTrack("track1", "stream1", transport.Codec{
+			MimeType:  webrtc.MimeTypeVP8,
+			ClockRate: 90000,
+		}, "a"),
+	}
+
+	const packetSize = 1000
+
+	for i := 0; i*packetSize < len(keyFrame); i++ {
+		end := (i + 1) * packetSize
+		if end > len(keyFrame) {
+			end = len(keyFrame)
+		}
+
+		var descriptor byte
+		if i == 0 {
+			descriptor = 0x10
+		}
+
+		mock.packets = append(mock.packets, &rtp.Packet{
+			Header: rtp.Header{
+				SequenceNumber: uint16(i),
+				Marker:         end == len(keyFrame),
+			},
+			Payload: append([]byte{descriptor}, keyFrame[i*packetSize:end]...),
+		})
+	}
+
+	track := thumbnailer.Track("room1", "a", mock, func() {})
+
+	for {
+		if _, _, err := track.ReadRTP(); err != nil {
+			require.Equal(t, io.EOF, err)
+
+			break
+		}
+	}
+
+	trackID := identifiers.TrackID{ID: "track1", StreamID: "stream1"}
+
+	var thumbnail sfu.Thumbnail
+
+	require.Eventually(t, func() bool {
+		var ok bool
+
+		thumbnail, ok = thumbnailer.Thumbnail("room1", trackID)
+
+		return ok
+	}, time.Second, 5*time.Millisecond)
+
+	assert.Equal(t, 150, thumbnail.Width)
+	assert.Equal(t, 100, thumbnail.Height)
+
+	img, err := jpeg.Decode(bytes.NewReader(thumbnail.JPEG))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 150, 100), img.Bounds())
+}
+
+func TestVP8KeyFrameDecoder(t *testing.T) {
+	t.Parallel()
+
+	keyFrame, err := ioutil.ReadFile("testdata/keyframe.vp8")
+	require.NoError(t, err)
+
+	var decoder sfu.VP8KeyFrameDecoder
+
+	img, err := decoder.DecodeKeyFrame(keyFrame)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 150, 100), img.Bounds())
+
+	// Flip the inverse keyframe bit.
+	interFrame := append([]byte{keyFrame[0] | 0x01}, keyFrame[1:]...)
+
+	_, err = decoder.DecodeKeyFrame(interFrame)
+	assert.Error(t, err)
+
+	_, err = decoder.DecodeKeyFrame(keyFrame[:5])
+	assert.Error(t, err)
+}
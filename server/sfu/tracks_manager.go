@@ -12,18 +12,27 @@ import (
 
 const DataChannelName = "data"
 
+type TracksManagerParams struct {
+	Log                 logger.Logger
+	JitterBufferEnabled bool
+	// Thumbnailer is optional.
+	Thumbnailer *Thumbnailer
+	// SimulcastLadders is optional.
+	SimulcastLadders *SimulcastLadders
+}
+
 type TracksManager struct {
-	log                 logger.Logger
-	mu                  sync.RWMutex
-	peerManagers        map[identifiers.RoomID]*PeerManager
-	jitterBufferEnabled bool
+	params       *TracksManagerParams
+	log          logger.Logger
+	mu           sync.RWMutex
+	peerManagers map[identifiers.RoomID]*PeerManager
 }
 
-func NewTracksManager(log logger.Logger, jitterBufferEnabled bool) *TracksManager {
+func NewTracksManager(params TracksManagerParams) *TracksManager {
 	return &TracksManager{
-		log:                 log.WithNamespaceAppended("tracks_manager"),
-		peerManagers:        map[identifiers.RoomID]*PeerManager{},
-		jitterBufferEnabled: jitterBufferEnabled,
+		params:       &params,
+		log:          params.Log.WithNamespaceAppended("tracks_manager"),
+		peerManagers: map[identifiers.RoomID]*PeerManager{},
 	}
 }
 
@@ -51,9 +60,15 @@ func (m *TracksManager) Add(room identifiers.RoomID, tr transport.Transport) (<-
 
 		jitterHandler := NewJitterHandler(
 			log,
-			m.jitterBufferEnabled,
+			m.params.JitterBufferEnabled,
 		)
-		peerManager = NewPeerManager(room, log, jitterHandler)
+		peerManager = NewPeerManager(PeerManagerParams{
+			Room:             room,
+			Log:              log,
+			JitterHandler:    jitterHandler,
+			Thumbnailer:      m.params.Thumbnailer,
+			SimulcastLadders: m.params.SimulcastLadders,
+		})
 		m.peerManagers[room] = peerManager
 	}
 
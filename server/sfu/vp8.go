@@ -0,0 +1,190 @@
+package sfu
+
+import (
+	"github.com/juju/errors"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+var errShortVP8Descriptor = errors.New("short vp8 payload descriptor")
+
+// vp8Descriptor contains the fields of the VP8 payload descriptor that are
+// not parsed by codecs.VP8Packet. See RFC 7741, section 4.2.
+type vp8Descriptor struct {
+	// FrameStart is true for the first packet of a frame.
+	FrameStart bool
+	// HasTID is true when the temporal layer index is present.
+	HasTID bool
+	// TID is the temporal layer index.
+	TID uint8
+	// LayerSync is true when the frame only depends on the base layer.
+	LayerSync bool
+	// HasPictureID is true when the PictureID is present.
+	HasPictureID bool
+	// PictureID is the 7-bit or 15-bit picture ID.
+	PictureID uint16
+	// PictureIDIndex is the index of the first PictureID byte in the payload.
+	PictureIDIndex int
+	// LongPictureID is true for a 15-bit PictureID.
+	LongPictureID bool
+}
+
+func parseVP8Descriptor(payload []byte) (vp8Descriptor, error) {
+	var d vp8Descriptor
+
+	if len(payload) == 0 {
+		return d, errors.Trace(errShortVP8Descriptor)
+	}
+
+	d.FrameStart = payload[0]&0x10 != 0 && payload[0]&0x07 == 0
+
+	if payload[0]&0x80 == 0 {
+		// No extended control bits.
+		return d, nil
+	}
+
+	if len(payload) < 2 {
+		return d, errors.Trace(errShortVP8Descriptor)
+	}
+
+	ext := payload[1]
+	i := 2
+
+	if ext&0x80 != 0 { // I: PictureID present.
+		if len(payload) <= i {
+			return d, errors.Trace(errShortVP8Descriptor)
+		}
+
+		d.HasPictureID = true
+		d.PictureIDIndex = i
+
+		if payload[i]&0x80 != 0 { // M: 15-bit PictureID.
+			if len(payload) <= i+1 {
+				return d, errors.Trace(errShortVP8Descriptor)
+			}
+
+			d.LongPictureID = true
+			d.PictureID = uint16(payload[i]&0x7F)<<8 | uint16(payload[i+1])
+			i++
+		} else {
+			d.PictureID = uint16(payload[i])
+		}
+
+		i++
+	}
+
+	if ext&0x40 != 0 { // L: TL0PICIDX present.
+		i++
+	}
+
+	if ext&0x20 == 0 && ext&0x10 == 0 { // Neither T nor K present.
+		return d, nil
+	}
+
+	if len(payload) <= i {
+		return d, errors.Trace(errShortVP8Descriptor)
+	}
+
+	if ext&0x20 != 0 { // T: TID present.
+		d.HasTID = true
+		d.TID = payload[i] >> 6
+		d.LayerSync = payload[i]&0x20 != 0
+	}
+
+	return d, nil
+}
+
+// rewriteVP8PictureID returns a copy of the payload with the PictureID
+// replaced. The PictureID wraps around at its 7-bit or 15-bit length.
+func rewriteVP8PictureID(payload []byte, d vp8Descriptor, pictureID uint16) []byte {
+	rewritten := make([]byte, len(payload))
+	copy(rewritten, payload)
+
+	i := d.PictureIDIndex
+
+	if d.LongPictureID {
+		pictureID &= 0x7FFF
+		rewritten[i] = 0x80 | byte(pictureID>>8)
+		rewritten[i+1] = byte(pictureID)
+	} else {
+		rewritten[i] = byte(pictureID & 0x7F)
+	}
+
+	return rewritten
+}
+
+// isVP8KeyFrameStart returns true for the first packet of a VP8 keyframe.
+func isVP8KeyFrameStart(payload []byte) bool {
+	var vp8 codecs.VP8Packet
+
+	frame, err := vp8.Unmarshal(payload)
+	if err != nil || len(frame) == 0 {
+		return false
+	}
+
+	// The inverse keyframe flag is in the first bit of the frame tag. See RFC
+	// 6386, section 9.1.
+	return vp8.S == 1 && vp8.PID == 0 && frame[0]&0x01 == 0
+}
+
+// vp8KeyFrameAssembler reassembles VP8 keyframes from RTP packets. All other
+// frames are skipped. It is not safe for concurrent use.
+type vp8KeyFrameAssembler struct {
+	frame []byte
+
+	// assembling is true while the packets of a keyframe are being collected.
+	assembling bool
+	lastSeq    uint16
+}
+
+// Push adds the packet to the current keyframe. It returns the complete
+// keyframe after the last packet of the frame has been pushed. The returned
+// slice is only valid until the next call to Push.
+func (a *vp8KeyFrameAssembler) Push(packet *rtp.Packet) ([]byte, bool) {
+	var vp8 codecs.VP8Packet
+
+	payload, err := vp8.Unmarshal(packet.Payload)
+	if err != nil || len(payload) == 0 {
+		a.reset()
+
+		return nil, false
+	}
+
+	if vp8.S == 1 && vp8.PID == 0 {
+		// The inverse keyframe flag is in the first bit of the frame tag. See
+		// RFC 6386, section 9.1.
+		isKeyFrame := payload[0]&0x01 == 0
+		if !isKeyFrame {
+			a.reset()
+
+			return nil, false
+		}
+
+		a.frame = append(a.frame[:0], payload...)
+		a.assembling = true
+	} else {
+		if !a.assembling || packet.SequenceNumber != a.lastSeq+1 {
+			// Lost packet or a continuation of a frame we are not interested in.
+			a.reset()
+
+			return nil, false
+		}
+
+		a.frame = append(a.frame, payload...)
+	}
+
+	a.lastSeq = packet.SequenceNumber
+
+	if !packet.Marker {
+		return nil, false
+	}
+
+	a.assembling = false
+
+	return a.frame, true
+}
+
+func (a *vp8KeyFrameAssembler) reset() {
+	a.assembling = false
+	a.frame = a.frame[:0]
+}
@@ -0,0 +1,48 @@
+package sfu
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/juju/errors"
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/image/vp8"
+)
+
+// VP8KeyFrameDecoder decodes VP8 keyframes using the pure Go decoder from
+// golang.org/x/image/vp8. It is safe for concurrent use.
+type VP8KeyFrameDecoder struct{}
+
+var _ KeyFrameDecoder = VP8KeyFrameDecoder{}
+
+// DefaultKeyFrameDecoders returns the decoders used when none are configured.
+func DefaultKeyFrameDecoders() map[string]KeyFrameDecoder {
+	return map[string]KeyFrameDecoder{
+		webrtc.MimeTypeVP8: VP8KeyFrameDecoder{},
+	}
+}
+
+// DecodeKeyFrame implements KeyFrameDecoder.
+func (VP8KeyFrameDecoder) DecodeKeyFrame(frame []byte) (image.Image, error) {
+	// A new decoder is used for every frame since vp8.Decoder is not safe for
+	// concurrent use, and only keyframes are decoded so no state needs to be
+	// kept between frames.
+	decoder := vp8.NewDecoder()
+	decoder.Init(bytes.NewReader(frame), len(frame))
+
+	header, err := decoder.DecodeFrameHeader()
+	if err != nil {
+		return nil, errors.Annotate(err, "decode frame header")
+	}
+
+	if !header.KeyFrame {
+		return nil, errors.Errorf("not a keyframe")
+	}
+
+	img, err := decoder.DecodeFrame()
+	if err != nil {
+		return nil, errors.Annotate(err, "decode frame")
+	}
+
+	return img, nil
+}
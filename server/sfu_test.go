@@ -18,6 +18,7 @@ import (
 	"github.com/peer-calls/peer-calls/v4/server/sfu"
 	"github.com/peer-calls/peer-calls/v4/server/test"
 	"github.com/peer-calls/peer-calls/v4/server/transport"
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 	"github.com/pion/webrtc/v3/pkg/media"
 	"github.com/stretchr/testify/assert"
@@ -31,16 +32,38 @@ func setupSFUServer(rooms server.RoomManager, jitterBufferEnabled bool) (s *http
 
 	handler := server.NewSFUHandler(
 		log,
-		server.NewWSS(log, rooms),
+		server.NewWSS(log, rooms, nil),
 		[]server.ICEServer{},
 		server.NetworkConfigSFU{},
-		sfu.NewTracksManager(log, jitterBufferEnabled),
+		sfu.NewTracksManager(sfu.TracksManagerParams{
+			Log:                 log,
+			JitterBufferEnabled: jitterBufferEnabled,
+		}),
 	)
 	s = httptest.NewServer(handler)
 	url = "ws" + strings.TrimPrefix(s.URL, "http") + "/ws/"
 	return
 }
 
+func TestSFU_Close(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	log := test.NewLogger()
+
+	handler := server.NewSFUHandler(
+		log,
+		server.NewWSS(log, nil, nil),
+		[]server.ICEServer{},
+		server.NetworkConfigSFU{
+			PeerStateWebhookURL: "http://localhost/hook",
+		},
+		nil,
+	)
+
+	// Close should stop the peer state webhook worker.
+	handler.Close()
+}
+
 func TestSFU_ConnectDisconnect(t *testing.T) {
 	log := test.NewLogger()
 
@@ -327,12 +350,36 @@ func TestSFU_OnTrack(t *testing.T) {
 
 	onTrackFired, onTrackFiredDone := context.WithCancel(ctx)
 	onTrackEOF, onTrackEOFDone := context.WithCancel(ctx)
+	onGoodbye, onGoodbyeDone := context.WithCancel(ctx)
 	pc2.OnTrack(func(remoteTrack *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 		log := log.WithCtx(logger.Ctx{"ssrc": remoteTrack.SSRC()})
 
 		log.Info("OnTrack", nil)
 
 		onTrackFiredDone()
+
+		// The server should send an RTCP BYE before the track is removed.
+		go func() {
+			for {
+				packets, _, err := receiver.ReadRTCP()
+				if err != nil {
+					return
+				}
+
+				for _, packet := range packets {
+					bye, ok := packet.(*rtcp.Goodbye)
+					if !ok {
+						continue
+					}
+
+					for _, source := range bye.Sources {
+						if source == uint32(remoteTrack.SSRC()) {
+							onGoodbyeDone()
+						}
+					}
+				}
+			}
+		}()
 		for {
 			_, _, err := remoteTrack.ReadRTP()
 			fmt.Println("got rtp", err)
@@ -383,6 +430,9 @@ func TestSFU_OnTrack(t *testing.T) {
 	log.Info("sending negotiate request (2)", nil)
 	wait(t, ctx, peerCtx1.signaller.Negotiate())
 
+	<-onGoodbye.Done()
+	assert.Equal(t, context.Canceled, onGoodbye.Err(), "test timed out")
+
 	<-onTrackEOF.Done()
 	assert.Equal(t, context.Canceled, onTrackEOF.Err(), "test timed out")
 
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/juju/errors"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/logger"
+)
+
+const defaultSnapshotTTL = time.Minute
+
+// RoomSnapshot contains the state of a single room at a point in time.
+type RoomSnapshot struct {
+	RoomID identifiers.RoomID `json:"roomId"`
+	// Clients contains the metadata of each client in the room.
+	Clients   map[identifiers.ClientID]string `json:"clients"`
+	Timestamp time.Time                       `json:"timestamp"`
+	// ResumeTokens are only set for rooms being migrated between nodes. A
+	// client must present its token to resume the session on the new node.
+	ResumeTokens map[identifiers.ClientID]string `json:"resumeTokens,omitempty"`
+}
+
+// Expired returns true when the snapshot is older than ttl.
+func (s RoomSnapshot) Expired(now time.Time, ttl time.Duration) bool {
+	return now.Sub(s.Timestamp) > ttl
+}
+
+// SnapshotStore persists room snapshots so they can be restored after a
+// server restart.
+type SnapshotStore interface {
+	SaveSnapshots(snapshots []RoomSnapshot) error
+	LoadSnapshots() ([]RoomSnapshot, error)
+}
+
+// RoomSnapshotter is implemented by RoomManagers that are able to snapshot
+// and restore the state of their rooms.
+type RoomSnapshotter interface {
+	Snapshot() []RoomSnapshot
+	Restore(snapshots []RoomSnapshot, ttl time.Duration)
+}
+
+// FileSnapshotStore stores snapshots as JSON in a local file. It is meant to
+// be used with the memory store.
+type FileSnapshotStore struct {
+	filename string
+}
+
+var _ SnapshotStore = &FileSnapshotStore{}
+
+func NewFileSnapshotStore(filename string) *FileSnapshotStore {
+	return &FileSnapshotStore{
+		filename: filename,
+	}
+}
+
+// SaveSnapshots writes the snapshots to a temporary file first and then
+// renames it so that a crash during write does not corrupt the last
+// snapshot.
+func (f *FileSnapshotStore) SaveSnapshots(snapshots []RoomSnapshot) error {
+	b, err := json.Marshal(snapshots)
+	if err != nil {
+		return errors.Annotate(err, "marshal snapshots")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.filename), filepath.Base(f.filename)+".*.tmp")
+	if err != nil {
+		return errors.Annotate(err, "create temp file")
+	}
+
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+
+		return errors.Annotatef(err, "write snapshots: %s", tmp.Name())
+	}
+
+	err = os.Rename(tmp.Name(), f.filename)
+
+	return errors.Annotatef(err, "rename snapshots: %s", f.filename)
+}
+
+// LoadSnapshots reads the snapshots from file. A missing file is not an
+// error.
+func (f *FileSnapshotStore) LoadSnapshots() ([]RoomSnapshot, error) {
+	b, err := ioutil.ReadFile(f.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Annotatef(err, "read snapshots: %s", f.filename)
+	}
+
+	var snapshots []RoomSnapshot
+
+	err = json.Unmarshal(b, &snapshots)
+
+	return snapshots, errors.Annotatef(err, "unmarshal snapshots: %s", f.filename)
+}
+
+// RedisSnapshotStore stores snapshots in a redis hash, indexed by room ID.
+// The hash is shared by all nodes using the same prefix, so every node only
+// overwrites the rooms from its own snapshot. Rooms older than ttl are removed
+// from the hash on save.
+type RedisSnapshotStore struct {
+	client *redis.Client
+	key    string
+	ttl    time.Duration
+}
+
+var _ SnapshotStore = &RedisSnapshotStore{}
+
+// redisSnapshotSaveAttempts is the number of times SaveSnapshots retries the
+// transaction when the hash is modified concurrently by another node.
+const redisSnapshotSaveAttempts = 3
+
+func NewRedisSnapshotStore(client *redis.Client, prefix string, ttl time.Duration) *RedisSnapshotStore {
+	if ttl == 0 {
+		ttl = defaultSnapshotTTL
+	}
+
+	return &RedisSnapshotStore{
+		client: client,
+		key:    prefix + ":snapshots",
+		ttl:    ttl,
+	}
+}
+
+func (r *RedisSnapshotStore) SaveSnapshots(snapshots []RoomSnapshot) error {
+	values := make(map[string]interface{}, len(snapshots))
+
+	for _, snapshot := range snapshots {
+		b, err := json.Marshal(snapshot)
+		if err != nil {
+			return errors.Annotatef(err, "marshal snapshot: %s", snapshot.RoomID)
+		}
+
+		values[snapshot.RoomID.String()] = string(b)
+	}
+
+	var err error
+
+	for i := 0; i < redisSnapshotSaveAttempts; i++ {
+		err = r.client.Watch(func(tx *redis.Tx) error {
+			return r.save(tx, values)
+		}, r.key)
+
+		if errors.Cause(err) != redis.TxFailedErr {
+			break
+		}
+	}
+
+	return errors.Annotatef(err, "save %s", r.key)
+}
+
+// save sets the fields for the rooms in values and removes the expired
+// rooms saved by any node. The transaction fails when another node modifies
+// the hash in the meantime.
+func (r *RedisSnapshotStore) save(tx *redis.Tx, values map[string]interface{}) error {
+	existing, err := tx.HGetAll(r.key).Result()
+	if err != nil {
+		return errors.Annotatef(err, "hgetall %s", r.key)
+	}
+
+	now := time.Now()
+
+	var expired []string
+
+	for roomID, value := range existing {
+		if _, ok := values[roomID]; ok {
+			continue
+		}
+
+		var snapshot RoomSnapshot
+
+		// Fields that cannot be parsed are removed as well.
+		if err := json.Unmarshal([]byte(value), &snapshot); err != nil || snapshot.Expired(now, r.ttl) {
+			expired = append(expired, roomID)
+		}
+	}
+
+	_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+		if len(expired) > 0 {
+			pipe.HDel(r.key, expired...)
+		}
+
+		if len(values) > 0 {
+			pipe.HSet(r.key, values)
+		}
+
+		return nil
+	})
+
+	return errors.Trace(err)
+}
+
+func (r *RedisSnapshotStore) LoadSnapshots() ([]RoomSnapshot, error) {
+	values, err := r.client.HGetAll(r.key).Result()
+	if err != nil {
+		return nil, errors.Annotatef(err, "hgetall %s", r.key)
+	}
+
+	snapshots := make([]RoomSnapshot, 0, len(values))
+
+	for roomID, value := range values {
+		var snapshot RoomSnapshot
+
+		if err := json.Unmarshal([]byte(value), &snapshot); err != nil {
+			return nil, errors.Annotatef(err, "unmarshal snapshot: %s", roomID)
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+type SnapshotterParams struct {
+	Log      logger.Logger
+	Store    SnapshotStore
+	Rooms    RoomSnapshotter
+	Interval time.Duration
+	TTL      time.Duration
+}
+
+// Snapshotter periodically saves the room state to SnapshotStore and
+// restores it on startup, so that clients reconnecting after a quick server
+// restart find their rooms the way they left them.
+type Snapshotter struct {
+	params *SnapshotterParams
+}
+
+func NewSnapshotter(params SnapshotterParams) *Snapshotter {
+	params.Log = params.Log.WithNamespaceAppended("snapshotter")
+
+	if params.TTL == 0 {
+		params.TTL = defaultSnapshotTTL
+	}
+
+	return &Snapshotter{
+		params: &params,
+	}
+}
+
+// Restore loads the snapshots from the store and restores the ones that have
+// not yet expired.
+func (s *Snapshotter) Restore() error {
+	snapshots, err := s.params.Store.LoadSnapshots()
+	if err != nil {
+		return errors.Annotate(err, "load snapshots")
+	}
+
+	now := time.Now()
+
+	restore := make([]RoomSnapshot, 0, len(snapshots))
+
+	for _, snapshot := range snapshots {
+		if !snapshot.Expired(now, s.params.TTL) {
+			restore = append(restore, snapshot)
+		}
+	}
+
+	s.params.Log.Info("Restore snapshots", logger.Ctx{
+		"num_loaded":   len(snapshots),
+		"num_restored": len(restore),
+	})
+
+	s.params.Rooms.Restore(restore, s.params.TTL)
+
+	return nil
+}
+
+// Save takes a snapshot of all rooms and saves it to the store.
+func (s *Snapshotter) Save() error {
+	err := s.params.Store.SaveSnapshots(s.params.Rooms.Snapshot())
+
+	return errors.Annotate(err, "save snapshots")
+}
+
+// Run saves snapshots periodically until the context is canceled. No
+// snapshot is saved on shutdown because by that time the clients might have
+// already been disconnected.
+func (s *Snapshotter) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.params.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				s.params.Log.Error("Save snapshots", errors.Trace(err), nil)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
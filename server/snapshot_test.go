@@ -0,0 +1,154 @@
+package server_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peer-calls/peer-calls/v4/server"
+	"github.com/peer-calls/peer-calls/v4/server/identifiers"
+	"github.com/peer-calls/peer-calls/v4/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+)
+
+func TestFileSnapshotStore(t *testing.T) {
+	t.Parallel()
+
+	store := server.NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshots.json"))
+
+	snapshots, err := store.LoadSnapshots()
+	assert.NoError(t, err)
+	assert.Nil(t, snapshots)
+
+	timestamp := time.Unix(1600000000, 0).UTC()
+
+	expected := []server.RoomSnapshot{{
+		RoomID: "room1",
+		Clients: map[identifiers.ClientID]string{
+			"a": "nick-a",
+			"b": "nick-b",
+		},
+		Timestamp: timestamp,
+	}}
+
+	require.NoError(t, store.SaveSnapshots(expected))
+
+	snapshots, err = store.LoadSnapshots()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, snapshots)
+}
+
+func TestRedisSnapshotStore(t *testing.T) {
+	pub, _, stop := configureRedis(t)
+	defer stop()
+
+	prefix := "peercalls-snapshot-test"
+
+	defer pub.Del(prefix + ":snapshots")
+
+	node1 := server.NewRedisSnapshotStore(pub, prefix, time.Minute)
+	node2 := server.NewRedisSnapshotStore(pub, prefix, time.Minute)
+
+	now := time.Now().UTC().Round(time.Second)
+
+	snapshot := func(roomID identifiers.RoomID, timestamp time.Time) server.RoomSnapshot {
+		return server.RoomSnapshot{
+			RoomID: roomID,
+			Clients: map[identifiers.ClientID]string{
+				"a": "nick-a",
+			},
+			Timestamp: timestamp,
+		}
+	}
+
+	require.NoError(t, node1.SaveSnapshots([]server.RoomSnapshot{
+		snapshot("room1", now),
+		snapshot("expired", now.Add(-2*time.Minute)),
+	}))
+
+	// Saving the rooms of another node must keep the rooms of the first node
+	// and remove the expired ones.
+	require.NoError(t, node2.SaveSnapshots([]server.RoomSnapshot{
+		snapshot("room2", now),
+	}))
+
+	snapshots, err := node1.LoadSnapshots()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []server.RoomSnapshot{
+		snapshot("room1", now),
+		snapshot("room2", now),
+	}, snapshots)
+}
+
+func TestAdapterRoomManager_Snapshot_Restore(t *testing.T) {
+	t.Parallel()
+
+	rooms := newMemoryRoomManager()
+
+	adapter, _ := rooms.Enter("room1")
+
+	client := server.NewClientWithID(NewMockWriter(), "a")
+	defer client.Close(websocket.StatusNormalClosure, "")
+
+	client.SetMetadata("nick-a")
+	require.NoError(t, adapter.Add(client))
+
+	snapshots := rooms.Snapshot()
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, identifiers.RoomID("room1"), snapshots[0].RoomID)
+	assert.Equal(t, map[identifiers.ClientID]string{"a": "nick-a"}, snapshots[0].Clients)
+
+	restoredRooms := newMemoryRoomManager()
+	restoredRooms.Restore(snapshots, time.Minute)
+
+	assert.Equal(t, snapshots, restoredRooms.Snapshot(), "restored rooms should be snapshotted")
+
+	_, ok := restoredRooms.RestoredMetadata("room1", "b", "")
+	assert.False(t, ok)
+
+	metadata, ok := restoredRooms.RestoredMetadata("room1", "a", "")
+	assert.True(t, ok)
+	assert.Equal(t, "nick-a", metadata)
+
+	_, ok = restoredRooms.RestoredMetadata("room1", "a", "")
+	assert.False(t, ok, "metadata should only be restored once")
+
+	assert.Empty(t, restoredRooms.Snapshot())
+}
+
+func TestSnapshotter_Restore_expired(t *testing.T) {
+	t.Parallel()
+
+	store := server.NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshots.json"))
+
+	require.NoError(t, store.SaveSnapshots([]server.RoomSnapshot{{
+		RoomID:    "expired",
+		Clients:   map[identifiers.ClientID]string{"a": "nick-a"},
+		Timestamp: time.Now().Add(-time.Hour),
+	}, {
+		RoomID:    "valid",
+		Clients:   map[identifiers.ClientID]string{"b": "nick-b"},
+		Timestamp: time.Now(),
+	}}))
+
+	rooms := newMemoryRoomManager()
+
+	snapshotter := server.NewSnapshotter(server.SnapshotterParams{
+		Log:      test.NewLogger(),
+		Store:    store,
+		Rooms:    rooms,
+		Interval: time.Second,
+		TTL:      time.Minute,
+	})
+
+	require.NoError(t, snapshotter.Restore())
+
+	_, ok := rooms.RestoredMetadata("expired", "a", "")
+	assert.False(t, ok)
+
+	metadata, ok := rooms.RestoredMetadata("valid", "b", "")
+	assert.True(t, ok)
+	assert.Equal(t, "nick-b", metadata)
+}
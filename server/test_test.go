@@ -57,6 +57,13 @@ func (w *MockWSWriter) Close(statusCode websocket.StatusCode, reason string) err
 	return nil
 }
 
+// newMemoryRoomManager creates a room manager with in-memory adapters.
+func newMemoryRoomManager() *server.AdapterRoomManager {
+	return server.NewAdapterRoomManager(func(roomID identifiers.RoomID) server.Adapter {
+		return server.NewMemoryAdapter(roomID)
+	})
+}
+
 func serialize(t *testing.T, msg message.Message) []byte {
 	t.Helper()
 	data, err := serializer.Serialize(msg)
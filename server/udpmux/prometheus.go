@@ -0,0 +1,11 @@
+package udpmux
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var prometheusOversizedPackets = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "udpmux_oversized_packets_total",
+	Help: "Total number of dropped UDP packets larger than the MTU",
+})
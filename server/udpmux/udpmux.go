@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"net"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/peer-calls/peer-calls/v4/server/logger"
@@ -249,10 +250,21 @@ func (m *UDPMux) startLoop() {
 	}
 }
 
+// oversizedLogInterval is the minimum interval between two logs about
+// dropped oversized datagrams. The socket is shared between all remote peers
+// so logging every datagram could easily flood the log.
+const oversizedLogInterval = time.Minute
+
 func (m *UDPMux) startReading(ctx context.Context) {
-	buf := make([]byte, m.params.MTU)
+	// The extra byte is used to detect datagrams larger than the MTU.
+	buf := make([]byte, m.params.MTU+1)
 	done := ctx.Done()
 
+	var (
+		oversizedDropped int
+		oversizedLogged  time.Time
+	)
+
 	defer close(m.remotePacketsChan)
 
 	for {
@@ -263,6 +275,27 @@ func (m *UDPMux) startReading(ctx context.Context) {
 			return
 		}
 
+		if i > int(m.params.MTU) {
+			// Datagrams larger than the buffer are truncated, so the packet cannot
+			// be used.
+			prometheusOversizedPackets.Inc()
+
+			oversizedDropped++
+
+			if now := time.Now(); now.Sub(oversizedLogged) >= oversizedLogInterval {
+				m.params.Log.Warn("Dropping oversized packets", logger.Ctx{
+					"remote_addr": raddr,
+					"mtu":         m.params.MTU,
+					"dropped":     oversizedDropped,
+				})
+
+				oversizedDropped = 0
+				oversizedLogged = now
+			}
+
+			continue
+		}
+
 		pkt := remotePacket{
 			bytes: make([]byte, i),
 			raddr: raddr,
@@ -59,6 +59,47 @@ func TestUDPMux_AcceptConn(t *testing.T) {
 	assert.Equal(t, "test", string(recv[:i]))
 }
 
+func TestUDPMux_OversizedPacket(t *testing.T) {
+	goleak.VerifyNone(t)
+	defer goleak.VerifyNone(t)
+
+	udpConn1, err := net.ListenUDP("udp", &net.UDPAddr{
+		IP:   net.IP{127, 0, 0, 1},
+		Port: 0,
+	})
+	require.NoError(t, err)
+	defer udpConn1.Close()
+
+	mux := New(Params{
+		Conn:         udpConn1,
+		MTU:          8,
+		Log:          test.NewLogger(),
+		ReadChanSize: 20,
+	})
+	defer mux.Close()
+
+	udpConn2, err := net.DialUDP("udp", nil, udpConn1.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer udpConn2.Close()
+
+	_, err = udpConn2.Write([]byte("oversized"))
+	require.NoError(t, err)
+
+	// Datagrams of exactly MTU size should not be dropped.
+	_, err = udpConn2.Write([]byte("test-mtu"))
+	require.NoError(t, err)
+
+	conn, err := mux.AcceptConn()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	recv := make([]byte, DefaultMTU)
+	i, err := conn.Read(recv)
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-mtu", string(recv[:i]))
+}
+
 func TestUDPMux_GetConn(t *testing.T) {
 	goleak.VerifyNone(t)
 	defer goleak.VerifyNone(t)
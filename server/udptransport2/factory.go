@@ -56,9 +56,17 @@ type FactoryParams struct {
 	DestroyTimeout time.Duration
 
 	InterceptorRegistry *interceptor.Registry
+
+	// ReceiveMTU is optional. servertransport.DefaultReceiveMTU will be used
+	// when zero.
+	ReceiveMTU int
 }
 
 func NewFactory(params FactoryParams) (*Factory, error) {
+	if params.ReceiveMTU == 0 {
+		params.ReceiveMTU = servertransport.DefaultReceiveMTU
+	}
+
 	params.Log = params.Log.WithNamespaceAppended("factory").WithCtx(logger.Ctx{
 		"local_addr":  params.Conn.LocalAddr(),
 		"remote_addr": params.Conn.RemoteAddr(),
@@ -71,7 +79,7 @@ func NewFactory(params FactoryParams) (*Factory, error) {
 	stringMux := stringmux.New(stringmux.Params{
 		Log:            params.Log,
 		Conn:           params.Conn,
-		MTU:            uint32(servertransport.ReceiveMTU), // TODO not sure if this is ok
+		MTU:            uint32(params.ReceiveMTU),
 		ReadChanSize:   readChanSize,
 		ReadBufferSize: 0,
 	})
@@ -189,7 +197,7 @@ func (f *Factory) init() (*factoryStreams, error) {
 		data: stringmux.New(stringmux.Params{
 			Log:            f.params.Log.WithNamespaceAppended("data"),
 			Conn:           dataConn,
-			MTU:            uint32(servertransport.ReceiveMTU),
+			MTU:            uint32(f.params.ReceiveMTU),
 			ReadBufferSize: readBufferSize,
 			ReadChanSize:   0,
 		}),
@@ -197,7 +205,7 @@ func (f *Factory) init() (*factoryStreams, error) {
 		metadata: stringmux.New(stringmux.Params{
 			Log:            f.params.Log.WithNamespaceAppended("metadata"),
 			Conn:           metadataConn,
-			MTU:            uint32(servertransport.ReceiveMTU),
+			MTU:            uint32(f.params.ReceiveMTU),
 			ReadBufferSize: readBufferSize,
 			ReadChanSize:   0,
 		}),
@@ -205,7 +213,7 @@ func (f *Factory) init() (*factoryStreams, error) {
 		media: stringmux.New(stringmux.Params{
 			Log:            f.params.Log.WithNamespaceAppended("media"),
 			Conn:           mediaConn,
-			MTU:            uint32(servertransport.ReceiveMTU),
+			MTU:            uint32(f.params.ReceiveMTU),
 			ReadBufferSize: readBufferSize,
 			ReadChanSize:   0,
 		}),
@@ -279,7 +287,15 @@ func (f *Factory) start(pingTicker clock.Ticker) {
 			return nil, errors.Trace(err)
 		}
 
-		transport := NewTransport(f.params.Log, streamID, mediaConn, dataConn, metadataConn, f.params.InterceptorRegistry)
+		transport := NewTransport(
+			f.params.Log,
+			streamID,
+			mediaConn,
+			dataConn,
+			metadataConn,
+			f.params.InterceptorRegistry,
+			f.params.ReceiveMTU,
+		)
 
 		return transport, nil
 	}
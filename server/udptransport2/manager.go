@@ -32,10 +32,17 @@ type ManagerParams struct {
 	PingTimeout         time.Duration
 	DestroyTimeout      time.Duration
 	InterceptorRegistry *interceptor.Registry
+	// ReceiveMTU is optional. servertransport.DefaultReceiveMTU will be used
+	// when zero.
+	ReceiveMTU int
 }
 
 func NewManager(params ManagerParams) *Manager {
 	params.Log = params.Log.WithNamespaceAppended("udptransport_manager")
+
+	if params.ReceiveMTU == 0 {
+		params.ReceiveMTU = servertransport.DefaultReceiveMTU
+	}
 	params.Log = params.Log.WithCtx(logger.Ctx{
 		"local_addr": params.Conn.LocalAddr(),
 	})
@@ -70,7 +77,7 @@ func (m *Manager) start() {
 
 	udpMux := udpmux.New(udpmux.Params{
 		Conn:           m.params.Conn,
-		MTU:            uint32(servertransport.ReceiveMTU),
+		MTU:            uint32(m.params.ReceiveMTU),
 		Log:            m.params.Log,
 		ReadChanSize:   readChanSize,
 		ReadBufferSize: 0,
@@ -122,6 +129,7 @@ func (m *Manager) start() {
 				PingTimeout:         m.params.PingTimeout,
 				DestroyTimeout:      m.params.DestroyTimeout,
 				InterceptorRegistry: m.params.InterceptorRegistry,
+				ReceiveMTU:          m.params.ReceiveMTU,
 			})
 
 			select {
@@ -24,6 +24,7 @@ func NewTransport(
 	dataConn stringmux.Conn,
 	metadataConn stringmux.Conn,
 	interceptorRegistry *interceptor.Registry,
+	receiveMTU int,
 ) *Transport {
 	closeWrite := func() {
 		mediaConn.CloseWrite()
@@ -38,6 +39,7 @@ func NewTransport(
 		MetadataConn:        metadataConn,
 		InterceptorRegistry: interceptorRegistry,
 		CodecRegistry:       nil,
+		ReceiveMTU:          receiveMTU,
 	}
 
 	return &Transport{
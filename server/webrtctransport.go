@@ -158,7 +158,7 @@ func RegisterCodecs(mediaEngine *webrtc.MediaEngine, registry *codecs.Registry)
 			webrtc.RTPHeaderExtensionCapability{
 				URI: ext.Parameter.URI,
 			},
-			webrtc.RTPCodecTypeAudio,
+			webrtc.RTPCodecTypeVideo,
 			ext.AllowedDirections...,
 		); err != nil {
 			panic(err)
@@ -182,6 +182,8 @@ type WebRTCTransport struct {
 
 	remoteTracksChannel chan transport.TrackRemoteWithRTCPReader
 
+	audioActivityChannel chan AudioActivityEvent
+
 	localTracks map[identifiers.TrackID]localTrack
 }
 
@@ -312,6 +314,8 @@ func NewWebRTCTransport(
 		localTracks: map[identifiers.TrackID]localTrack{},
 
 		remoteTracksChannel: make(chan transport.TrackRemoteWithRTCPReader),
+
+		audioActivityChannel: make(chan AudioActivityEvent, audioActivityChannelSize),
 	}
 	peerConnection.OnTrack(transport.handleTrack)
 
@@ -356,6 +360,11 @@ func (p *WebRTCTransport) WriteRTCP(packets []rtcp.Packet) error {
 	return errors.Annotate(err, "write rtcp")
 }
 
+// StateMachine returns the state machine of the underlying peer connection.
+func (p *WebRTCTransport) StateMachine() *PeerStateMachine {
+	return p.signaller.StateMachine()
+}
+
 func (p *WebRTCTransport) Done() <-chan struct{} {
 	return p.signaller.Done()
 }
@@ -378,6 +387,15 @@ func (p *WebRTCTransport) RemoveTrack(trackID identifiers.TrackID) error {
 		return errors.Errorf("track %s not found", trackID)
 	}
 
+	// Let the subscriber know the stream has ended before renegotiation takes
+	// place so that it can tear down the track right away.
+	if err := p.sendGoodbye(pta.sender); err != nil {
+		p.log.Warn("Send RTCP BYE", logger.Ctx{
+			"track_id": trackID,
+			"error":    err.Error(),
+		})
+	}
+
 	err := p.peerConnection.RemoveTrack(pta.sender)
 	if err != nil {
 		return errors.Annotate(err, "remove track")
@@ -390,6 +408,22 @@ func (p *WebRTCTransport) RemoveTrack(trackID identifiers.TrackID) error {
 	return nil
 }
 
+func (p *WebRTCTransport) sendGoodbye(sender *webrtc.RTPSender) error {
+	encodings := sender.GetParameters().Encodings
+
+	sources := make([]uint32, 0, len(encodings))
+
+	for _, encoding := range encodings {
+		sources = append(sources, uint32(encoding.SSRC))
+	}
+
+	return errors.Trace(p.WriteRTCP([]rtcp.Packet{
+		&rtcp.Goodbye{
+			Sources: sources,
+		},
+	}))
+}
+
 var _ transport.Transport = &WebRTCTransport{}
 
 func (p *WebRTCTransport) AddTrack(t transport.Track) (transport.TrackLocal, transport.RTCPReader, error) {
@@ -491,6 +525,19 @@ func (p *WebRTCTransport) handleTrack(track *webrtc.TrackRemote, receiver *webrt
 		RTCPReader:  receiver,
 	}
 
+	if rid := track.RID(); rid != "" {
+		trwr.RTCPReader = simulcastRTCPReader{
+			receiver: receiver,
+			rid:      rid,
+		}
+	}
+
+	if track.Kind() == webrtc.RTPCodecTypeAudio {
+		if extensionID, ok := audioLevelExtensionID(receiver); ok {
+			trwr.TrackRemote = newAudioLevelTrack(t, extensionID, p.sendAudioActivity)
+		}
+	}
+
 	select {
 	case p.remoteTracksChannel <- trwr:
 	case <-p.signaller.Done():
@@ -505,6 +552,23 @@ func (p *WebRTCTransport) handleTrack(track *webrtc.TrackRemote, receiver *webrt
 	// 		prometheusWebRTCTracksDuration.Observe(time.Since(start).Seconds())
 }
 
+// AudioActivityChannel emits sustained speech and silence detected in the
+// published audio tracks. Events are dropped when the channel is not read.
+func (p *WebRTCTransport) AudioActivityChannel() <-chan AudioActivityEvent {
+	return p.audioActivityChannel
+}
+
+func (p *WebRTCTransport) sendAudioActivity(event AudioActivityEvent) {
+	select {
+	case p.audioActivityChannel <- event:
+	default:
+		p.log.Warn("Audio activity channel full, dropping event", logger.Ctx{
+			"track_id": event.TrackID,
+			"activity": event.Activity,
+		})
+	}
+}
+
 func (p *WebRTCTransport) Signal(signal message.Signal) error {
 	err := p.signaller.Signal(signal)
 
@@ -540,3 +604,16 @@ type RemoteTrack struct {
 func (t RemoteTrack) Track() transport.Track {
 	return t.track
 }
+
+// simulcastRTCPReader reads the RTCP packets of a single simulcast layer,
+// since RTPReceiver.ReadRTCP reads only the packets of the first layer.
+type simulcastRTCPReader struct {
+	receiver *webrtc.RTPReceiver
+	rid      string
+}
+
+func (r simulcastRTCPReader) ReadRTCP() ([]rtcp.Packet, interceptor.Attributes, error) {
+	packets, attributes, err := r.receiver.ReadSimulcastRTCP(r.rid)
+
+	return packets, attributes, errors.Trace(err)
+}
@@ -16,6 +16,7 @@ type Signaller struct {
 	peerConnection *webrtc.PeerConnection
 	initiator      bool
 	negotiator     *Negotiator
+	stateMachine   *PeerStateMachine
 
 	signalMu      sync.Mutex
 	closed        bool
@@ -41,6 +42,7 @@ func NewSignaller(
 		signalChannel:   make(chan message.Signal),
 		closeChannel:    make(chan struct{}),
 		descriptionSent: make(chan struct{}),
+		stateMachine:    NewPeerStateMachine(),
 	}
 
 	negotiator := NewNegotiator(
@@ -104,14 +106,22 @@ func (s *Signaller) Initiator() bool {
 	return s.initiator
 }
 
+// StateMachine returns the state machine of the peer connection. Listeners
+// should be registered before the remote signals are handled so that no
+// transitions are missed.
+func (s *Signaller) StateMachine() *PeerStateMachine {
+	return s.stateMachine
+}
+
 func (s *Signaller) handleICEConnectionStateChange(connectionState webrtc.ICEConnectionState) {
+	state := s.stateMachine.HandleICEConnectionState(connectionState)
+
 	s.log.Info("Peer connection state changed", logger.Ctx{
 		"connection_state": connectionState,
+		"peer_state":       state,
 	})
 
-	if connectionState == webrtc.ICEConnectionStateClosed ||
-		connectionState == webrtc.ICEConnectionStateDisconnected ||
-		connectionState == webrtc.ICEConnectionStateFailed {
+	if state == PeerStateClosed {
 		s.Close()
 	}
 }
@@ -151,6 +161,11 @@ func (s *Signaller) Close() (err error) {
 		err = errors.Annotate(s.peerConnection.Close(), "close")
 	})
 	s.closeDescriptionSent()
+
+	// The transition fails when the state machine has already been closed by
+	// the ICE connection state change, which is fine.
+	_ = s.stateMachine.Transition(PeerStateClosed)
+
 	return
 }
 
@@ -2,7 +2,9 @@ package server
 
 import (
 	"net/http"
+	"net/url"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,16 +16,91 @@ import (
 	"nhooyr.io/websocket"
 )
 
+// metadataRestorer is implemented by RoomManagers that can restore client
+// metadata from a snapshot taken before a restart.
+type metadataRestorer interface {
+	RestoredMetadata(room identifiers.RoomID, clientID identifiers.ClientID, resumeToken string) (string, bool)
+}
+
 type WSS struct {
 	log   logger.Logger
 	rooms RoomManager
+	// originPatterns are the host patterns of other origins that are allowed
+	// to connect, for example the nodes that migrate rooms to this node.
+	originPatterns []string
+
+	mu sync.Mutex
+	// websocketCtxs contains the currently open connections.
+	websocketCtxs map[*WebsocketContext]struct{}
 }
 
-func NewWSS(log logger.Logger, rooms RoomManager) *WSS {
+func NewWSS(log logger.Logger, rooms RoomManager, originPatterns []string) *WSS {
 	return &WSS{
-		log:   log.WithNamespaceAppended("wss"),
-		rooms: rooms,
+		log:            log.WithNamespaceAppended("wss"),
+		rooms:          rooms,
+		originPatterns: originPatterns,
+		websocketCtxs:  map[*WebsocketContext]struct{}{},
+	}
+}
+
+// CloseAll closes all open websocket connections and waits until they are
+// closed. The clients will try to reconnect.
+func (wss *WSS) CloseAll(statusCode websocket.StatusCode, reason string) {
+	wss.mu.Lock()
+
+	websocketCtxs := make([]*WebsocketContext, 0, len(wss.websocketCtxs))
+	for websocketCtx := range wss.websocketCtxs {
+		websocketCtxs = append(websocketCtxs, websocketCtx)
+	}
+
+	wss.mu.Unlock()
+
+	wss.log.Info("Close all websocket connections", logger.Ctx{
+		"num_connections": len(websocketCtxs),
+		"reason":          reason,
+	})
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(websocketCtxs))
+
+	// Each Close waits for the close handshake, so the connections are closed
+	// concurrently.
+	for _, websocketCtx := range websocketCtxs {
+		go func(websocketCtx *WebsocketContext) {
+			defer wg.Done()
+
+			// The error is ignored because the connection might already be closed.
+			_ = websocketCtx.Close(statusCode, reason)
+		}(websocketCtx)
 	}
+
+	wg.Wait()
+}
+
+// originAllowed returns true when the Origin host of the request matches one
+// of the origin patterns. Requests from the same host are always accepted by
+// websocket.Accept.
+func (wss *WSS) originAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Host)
+
+	for _, pattern := range wss.originPatterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+
+	return false
 }
 
 type WebsocketContext struct {
@@ -32,6 +109,11 @@ type WebsocketContext struct {
 	client    *Client
 	onClose   func()
 	closeOnce sync.Once
+
+	// restoredMetadata is the metadata the client had before a restart or a
+	// migration. It is kept apart from the client metadata because non-empty
+	// metadata marks the client as ready.
+	restoredMetadata string
 }
 
 // NewWebsocketContext initializes the new websocket context. Users must call
@@ -62,6 +144,12 @@ func (w *WebsocketContext) ClientID() identifiers.ClientID {
 	return w.client.ID()
 }
 
+// RestoredMetadata returns the metadata restored from a snapshot, or an empty
+// string when there was nothing to restore.
+func (w *WebsocketContext) RestoredMetadata() string {
+	return w.restoredMetadata
+}
+
 // Messages returns the parsed messages channel.
 func (w *WebsocketContext) Messages() <-chan message.Message {
 	return w.client.Messages()
@@ -83,6 +171,8 @@ func (w *WebsocketContext) Close(statusCode websocket.StatusCode, reason string)
 func (wss *WSS) NewWebsocketContext(w http.ResponseWriter, r *http.Request) (*WebsocketContext, error) {
 	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{
 		CompressionMode: websocket.CompressionDisabled,
+		// The origin check of websocket.Accept only allows the same host.
+		InsecureSkipVerify: wss.originAllowed(r),
 	})
 	if err != nil {
 		prometheusWSConnErrTotal.Inc()
@@ -105,6 +195,17 @@ func (wss *WSS) NewWebsocketContext(w http.ResponseWriter, r *http.Request) (*We
 
 	client := NewClientWithID(c, clientID)
 
+	var restoredMetadata string
+
+	if restorer, ok := wss.rooms.(metadataRestorer); ok {
+		resumeToken := r.URL.Query().Get("resumeToken")
+
+		if metadata, ok := restorer.RestoredMetadata(room, clientID, resumeToken); ok {
+			log.Info("Restore metadata from snapshot", nil)
+			restoredMetadata = metadata
+		}
+	}
+
 	log.Info("New websocket connection", nil)
 
 	prometheusWSConnTotal.Inc()
@@ -120,7 +221,29 @@ func (wss *WSS) NewWebsocketContext(w http.ResponseWriter, r *http.Request) (*We
 		return nil, errors.Annotatef(err, "adapter add")
 	}
 
-	websocketCtx := NewWebsocketContext(adapter, client, room, func() {
+	if drainer, ok := wss.rooms.(roomDrainer); ok {
+		if targetURL, ok := drainer.Draining(room); ok {
+			log.Info("Room is draining, redirect client", logger.Ctx{
+				"target_url": targetURL,
+			})
+
+			err := client.Write(message.NewMigrate(room, message.Migrate{
+				URL:         targetURL,
+				ResumeToken: "",
+			}))
+			if err != nil {
+				log.Error("Write migrate", errors.Trace(err), nil)
+			}
+		}
+	}
+
+	var websocketCtx *WebsocketContext
+
+	websocketCtx = NewWebsocketContext(adapter, client, room, func() {
+		wss.mu.Lock()
+		delete(wss.websocketCtxs, websocketCtx)
+		wss.mu.Unlock()
+
 		prometheusWSConnActive.Dec()
 		duration := time.Since(start)
 		prometheusWSConnDuration.Observe(duration.Seconds())
@@ -136,5 +259,22 @@ func (wss *WSS) NewWebsocketContext(w http.ResponseWriter, r *http.Request) (*We
 		wss.rooms.Exit(room)
 	})
 
+	websocketCtx.restoredMetadata = restoredMetadata
+
+	wss.mu.Lock()
+	wss.websocketCtxs[websocketCtx] = struct{}{}
+	wss.mu.Unlock()
+
 	return websocketCtx, nil
 }
+
+// readyMetadata returns the metadata to set once the client has sent the
+// ready message. The nickname from the ready message takes precedence over
+// the restored metadata.
+func readyMetadata(nickname string, restoredMetadata string) string {
+	if nickname == "" {
+		return restoredMetadata
+	}
+
+	return nickname
+}
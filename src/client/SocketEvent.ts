@@ -30,6 +30,13 @@ export interface PubTrackEvent extends PubTrack {
   type: TrackEventType.Add | TrackEventType.Remove
 }
 
+// MicHintType maps to message.MicHintType.
+export type MicHintType = 'talkingWhileMuted' | 'silent'
+
+// PeerStateType maps to server.PeerState.
+export type PeerStateType =
+  'new' | 'connecting' | 'connected' | 'reconnecting' | 'closed'
+
 // TrackKind maps to transport.TrackKind.
 export type TrackKind = 'audio' | 'video'
 
@@ -59,4 +66,25 @@ export interface SocketEvent {
   connect: undefined
   disconnect: undefined
   ready: Ready
+  // migrate is sent when the room is moved to another server.
+  migrate: {
+    // url is the base URL of the other server.
+    url: string
+    resumeToken?: string
+  }
+  // mute reports the microphone state to the server.
+  mute: {
+    muted: boolean
+  }
+  // micHint is an advisory event sent by the server when the microphone
+  // state does not seem to match the audio.
+  micHint: {
+    type: MicHintType
+  }
+  // peerState is sent when the state of the server-side peer connection
+  // changes.
+  peerState: {
+    from: PeerStateType
+    to: PeerStateType
+  }
 }
@@ -1,2 +1,7 @@
 import { EventEmitter } from 'events'
+
+export function getWebSocketUrl(baseUrl: string, resumeToken = ''): string {
+  return baseUrl + (resumeToken ? '?resumeToken=' + resumeToken : '')
+}
+
 export default new EventEmitter()
@@ -1,6 +1,6 @@
 import { GetAsyncAction, makeAction } from '../async'
-import { DIAL, HANG_UP, ME, SOCKET_CONNECTED, SOCKET_DISCONNECTED, SOCKET_EVENT_HANG_UP, SOCKET_EVENT_USERS } from '../constants'
-import socket from '../socket'
+import { DIAL, HANG_UP, ME, SOCKET_CONNECTED, SOCKET_DISCONNECTED, SOCKET_EVENT_HANG_UP, SOCKET_EVENT_MIGRATE, SOCKET_EVENT_USERS } from '../constants'
+import socket, { getWebSocketUrl } from '../socket'
 import store, { ThunkResult } from '../store'
 import { config } from '../window'
 import * as NotifyActions from './NotifyActions'
@@ -64,6 +64,13 @@ export const init = (): ThunkResult<Promise<void>> => async (
       dispatch(NotifyActions.error('Server socket disconnected'))
       dispatch(disconnected())
     })
+    // The server is migrating the room to another node. The session is
+    // resumed there using the resume token, and the call is redialed once
+    // the socket connects.
+    socket.on(SOCKET_EVENT_MIGRATE, ({ url, resumeToken }) => {
+      dispatch(NotifyActions.info('Moving call to another server...'))
+      socket.reconnect(getWebSocketUrl(url, resumeToken))
+    })
   })
 }
 
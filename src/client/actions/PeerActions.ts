@@ -4,6 +4,7 @@ import Peer, { SignalData } from 'simple-peer'
 import { Decoder } from '../codec'
 import * as constants from '../constants'
 import { insertableStreamsCodec } from '../insertable-streams'
+import { getWebRTC } from '../simulcast'
 import { ClientSocket } from '../socket'
 import { Dispatch, GetState } from '../store'
 import { TextDecoder } from '../textcodec'
@@ -183,6 +184,7 @@ export function createPeer (options: CreatePeerOptions) {
         offerToReceiveVideo: true,
       },
       stream,
+      wrtc: getWebRTC(peerConfig.simulcast),
     })
 
     const handler = new PeerHandler({
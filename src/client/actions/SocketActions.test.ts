@@ -103,6 +103,64 @@ describe('SocketActions', () => {
         expect((instances[0].signal as jest.Mock).mock.calls.length).toBe(0)
       })
     })
+
+    describe('mute', () => {
+      let muted: boolean[]
+      beforeEach(() => {
+        muted = []
+        socket.on(constants.SOCKET_EVENT_MUTE, (payload: SocketEvent['mute']) => {
+          muted.push(payload.muted)
+        })
+        store.dispatch({ type: constants.SOCKET_CONNECTED })
+        SocketActions.handshake({ nickname, socket, roomName, peerId, store })
+      })
+
+      it('reports the microphone state only when it changes', () => {
+        store.dispatch({ type: constants.SOCKET_CONNECTED })
+        expect(muted).toEqual([ !store.getState().media.audio.enabled ])
+      })
+    })
+
+    describe('notifications', () => {
+      beforeEach(() => {
+        SocketActions.handshake({ nickname, socket, roomName, peerId, store })
+      })
+
+      function messages() {
+        return Object.values(store.getState().notifications)
+        .map(n => n.message)
+      }
+
+      it('shows a notification for mic hints', () => {
+        socket.emit(constants.SOCKET_EVENT_MIC_HINT, {
+          type: 'talkingWhileMuted',
+        })
+        expect(messages()).toEqual([
+          'You are talking while your microphone is muted',
+        ])
+      })
+
+      it('shows a notification when the peer connection is interrupted', () => {
+        socket.emit(constants.SOCKET_EVENT_PEER_STATE, {
+          from: 'new',
+          to: 'connected',
+        })
+        expect(messages()).toEqual([])
+
+        socket.emit(constants.SOCKET_EVENT_PEER_STATE, {
+          from: 'connected',
+          to: 'reconnecting',
+        })
+        socket.emit(constants.SOCKET_EVENT_PEER_STATE, {
+          from: 'reconnecting',
+          to: 'connected',
+        })
+        expect(messages()).toEqual([
+          'Connection to the server was interrupted, reconnecting...',
+          'Reconnected to the server',
+        ])
+      })
+    })
   })
 
   describe('peer events', () => {
@@ -4,13 +4,17 @@ import * as NotifyActions from '../actions/NotifyActions'
 import * as PeerActions from '../actions/PeerActions'
 import * as constants from '../constants'
 import { ClientSocket } from '../socket'
+import { MicMonitor } from '../mic'
 import { Dispatch, GetState, Store } from '../store'
 import { removeNickname, setNicknames } from './NicknameActions'
-import { pubTrackEvent } from './StreamActions'
+import { pubTrackEvent, StreamTypeCamera } from './StreamActions'
 
 const debug = _debug('peercalls')
 const sdpDebug = _debug('peercalls:sdp')
 
+const talkingWhileMutedMessage =
+  'You are talking while your microphone is muted'
+
 export interface SocketHandlerOptions {
   socket: ClientSocket
   roomName: string
@@ -27,6 +31,9 @@ class SocketHandler {
   dispatch: Dispatch
   getState: GetState
   peerId: string
+  // muted is the last microphone state sent to the server.
+  muted?: boolean
+  micMonitor: MicMonitor
 
   constructor (options: SocketHandlerOptions) {
     this.socket = options.socket
@@ -35,6 +42,8 @@ class SocketHandler {
     this.dispatch = options.dispatch
     this.getState = options.getState
     this.peerId = options.peerId
+    this.micMonitor = new MicMonitor(() => this.dispatch(
+      NotifyActions.warning(talkingWhileMutedMessage)))
   }
   handleSignal = ({ peerId, signal }: SocketEvent['signal']) => {
     const { getState } = this
@@ -74,6 +83,56 @@ class SocketHandler {
       stream,
     })(dispatch, getState))
   }
+  // reportMute sends the microphone state to the server when it changes. The
+  // microphone is muted by disabling its track, so it keeps sending audio.
+  reportMute = () => {
+    const state = this.getState()
+    const muted = !state.media.audio.enabled
+
+    if (state.media.socketConnected && muted !== this.muted) {
+      debug('socket mute: %s', muted)
+      this.muted = muted
+      this.socket.emit(constants.SOCKET_EVENT_MUTE, { muted })
+    }
+
+    const localStream = state.streams.localStreams[StreamTypeCamera]
+    const track = localStream && localStream.stream.getAudioTracks()[0]
+
+    this.micMonitor.monitor(muted ? track : undefined)
+  }
+  handleMicHint = ({ type }: SocketEvent['micHint']) => {
+    const { dispatch } = this
+    debug('socket micHint: %s', type)
+
+    switch (type) {
+      case 'talkingWhileMuted':
+        dispatch(NotifyActions.warning(talkingWhileMutedMessage))
+        break
+      case 'silent':
+        dispatch(NotifyActions.warning(
+          'No sound is coming from your microphone'))
+        break
+    }
+  }
+  handlePeerState = ({ from, to }: SocketEvent['peerState']) => {
+    const { dispatch } = this
+    debug('socket peerState: %s -> %s', from, to)
+
+    switch (to) {
+      case 'reconnecting':
+        dispatch(NotifyActions.warning(
+          'Connection to the server was interrupted, reconnecting...'))
+        break
+      case 'connected':
+        if (from === 'reconnecting') {
+          dispatch(NotifyActions.info('Reconnected to the server'))
+        }
+        break
+      case 'closed':
+        dispatch(NotifyActions.error('Connection to the server was lost'))
+        break
+    }
+  }
   handlePub = (pubTrack: SocketEvent['pubTrack']) => {
     const { dispatch } = this
     const { trackId, pubClientId, type } = pubTrack
@@ -99,6 +158,9 @@ export interface HandshakeOptions {
   stream?: MediaStream
 }
 
+// stopHandler stops the store subscription of the last handshake.
+let stopHandler = () => {}
+
 export function handshake (options: HandshakeOptions) {
   const { nickname, socket, roomName, stream, peerId, store } = options
 
@@ -118,6 +180,8 @@ export function handshake (options: HandshakeOptions) {
   socket.on(constants.SOCKET_EVENT_USERS, handler.handleUsers)
   socket.on(constants.SOCKET_EVENT_HANG_UP, handler.handleHangUp)
   socket.on(constants.SOCKET_EVENT_PUB_TRACK, handler.handlePub)
+  socket.on(constants.SOCKET_EVENT_MIC_HINT, handler.handleMicHint)
+  socket.on(constants.SOCKET_EVENT_PEER_STATE, handler.handlePeerState)
 
   debug('peerId: %s', peerId)
   socket.emit(constants.SOCKET_EVENT_READY, {
@@ -125,6 +189,14 @@ export function handshake (options: HandshakeOptions) {
     nickname,
     peerId,
   })
+
+  handler.reportMute()
+  const unsubscribe = store.subscribe(handler.reportMute)
+
+  stopHandler = () => {
+    unsubscribe()
+    handler.micMonitor.stop()
+  }
 }
 
 export function removeEventListeners (socket: ClientSocket) {
@@ -132,4 +204,9 @@ export function removeEventListeners (socket: ClientSocket) {
   socket.removeAllListeners(constants.SOCKET_EVENT_USERS)
   socket.removeAllListeners(constants.SOCKET_EVENT_HANG_UP)
   socket.removeAllListeners(constants.SOCKET_EVENT_PUB_TRACK)
+  socket.removeAllListeners(constants.SOCKET_EVENT_MIC_HINT)
+  socket.removeAllListeners(constants.SOCKET_EVENT_PEER_STATE)
+
+  stopHandler()
+  stopHandler = () => {}
 }
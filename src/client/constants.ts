@@ -66,6 +66,10 @@ export const SOCKET_EVENT_USERS = 'users'
 export const SOCKET_EVENT_HANG_UP = 'hangUp'
 export const SOCKET_EVENT_PUB_TRACK = 'pubTrack'
 export const SOCKET_EVENT_SUB_TRACK = 'subTrack'
+export const SOCKET_EVENT_MIGRATE = 'migrate'
+export const SOCKET_EVENT_MUTE = 'mute'
+export const SOCKET_EVENT_MIC_HINT = 'micHint'
+export const SOCKET_EVENT_PEER_STATE = 'peerState'
 
 export const STREAM_ADD = 'PEER_STREAM_ADD'
 export const STREAM_REMOVE = 'PEER_STREAM_REMOVE'
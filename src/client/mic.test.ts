@@ -0,0 +1,44 @@
+jest.mock('./audio')
+import { MicMonitor } from './mic'
+
+describe('mic', () => {
+
+  describe('MicMonitor', () => {
+    let now: number
+    let onSpeech: jest.Mock<void, []>
+    let monitor: MicMonitor
+    beforeEach(() => {
+      now = 1000
+      onSpeech = jest.fn()
+      monitor = new MicMonitor(onSpeech, () => now)
+    })
+
+    function speak(volume: number, durationMillis: number) {
+      for (let t = 0; t <= durationMillis; t += 100) {
+        monitor.handleAudioMessage({ volume } as any)
+        now += 100
+      }
+    }
+
+    it('calls onSpeech when speech is sustained', () => {
+      speak(0.5, 1000)
+      expect(onSpeech.mock.calls.length).toBe(0)
+      speak(0.5, 1000)
+      expect(onSpeech.mock.calls.length).toBe(1)
+    })
+
+    it('does not call onSpeech on silence', () => {
+      speak(0.01, 3000)
+      expect(onSpeech.mock.calls.length).toBe(0)
+    })
+
+    it('calls onSpeech at most once per interval', () => {
+      speak(0.5, 5000)
+      expect(onSpeech.mock.calls.length).toBe(1)
+      now += 30000
+      speak(0.5, 2000)
+      expect(onSpeech.mock.calls.length).toBe(2)
+    })
+  })
+
+})
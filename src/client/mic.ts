@@ -0,0 +1,91 @@
+import _debug from 'debug'
+import { AudioMessage, audioProcessor } from './audio'
+
+const debug = _debug('peercalls')
+
+// monitorStreamId is the AudioProcessor stream ID of the muted microphone.
+const monitorStreamId = 'mic-monitor'
+
+// speechVolume is the volume above which the muted microphone is considered
+// to pick up speech.
+const speechVolume = 0.05
+// speechDurationMillis is for how long the speech needs to be sustained
+// before onSpeech is called.
+const speechDurationMillis = 1500
+// speechIntervalMillis is the minimum interval between two onSpeech calls.
+const speechIntervalMillis = 30000
+
+// MicMonitor detects speech on a muted microphone.
+//
+// The microphone is muted by disabling its track so that the peer connection
+// keeps sending (silent) audio. A disabled track carries no speech, so the
+// volume is measured on an enabled clone of the track that is never sent.
+export class MicMonitor {
+  private track: MediaStreamTrack | undefined
+  private stopMonitor = () => {}
+  private speechStart = 0
+  private lastSpeech = -Infinity
+
+  constructor(
+    private readonly onSpeech: () => void,
+    private readonly now = () => Date.now(),
+  ) {}
+
+  // monitor starts monitoring the muted track. It stops monitoring when track
+  // is undefined.
+  monitor(track: MediaStreamTrack | undefined) {
+    if (track === this.track) {
+      return
+    }
+
+    this.stop()
+
+    if (!track) {
+      return
+    }
+
+    debug('MicMonitor.monitor: %s', track.id)
+
+    const clone = track.clone()
+    clone.enabled = true
+
+    audioProcessor.addTrack(monitorStreamId, clone)
+    const unsubscribe = audioProcessor
+    .subscribe(monitorStreamId, this.handleAudioMessage)
+
+    this.track = track
+    this.stopMonitor = () => {
+      unsubscribe()
+      audioProcessor.removeTrack(monitorStreamId)
+      clone.stop()
+    }
+  }
+
+  stop() {
+    this.stopMonitor()
+    this.stopMonitor = () => {}
+    this.track = undefined
+    this.speechStart = 0
+  }
+
+  handleAudioMessage = (msg: AudioMessage) => {
+    if (msg.volume < speechVolume) {
+      this.speechStart = 0
+      return
+    }
+
+    const now = this.now()
+
+    if (!this.speechStart) {
+      this.speechStart = now
+    }
+
+    if (
+      now - this.speechStart >= speechDurationMillis &&
+      now - this.lastSpeech >= speechIntervalMillis
+    ) {
+      this.lastSpeech = now
+      this.onSpeech()
+    }
+  }
+}
@@ -0,0 +1,72 @@
+import { createSimulcastPeerConnection, getWebRTC } from './simulcast'
+
+describe('simulcast', () => {
+
+  const ladder = [{
+    rid: 'q',
+    scaleResolutionDownBy: 4,
+    maxBitrate: 150000,
+  }, {
+    rid: 'f',
+    scaleResolutionDownBy: 1,
+    maxBitrate: 1500000,
+  }]
+
+  // The methods are defined on the prototype so that they can be overridden.
+  class PeerConnectionMock {
+    addTrackCalls: unknown[][] = []
+    addTransceiverCalls: unknown[][] = []
+    addTrack(...args: unknown[]) {
+      this.addTrackCalls.push(args)
+      return 'audio-sender'
+    }
+    addTransceiver(...args: unknown[]) {
+      this.addTransceiverCalls.push(args)
+      return { sender: 'video-sender' }
+    }
+  }
+
+  function createPeerConnection() {
+    const SimulcastPeerConnection = createSimulcastPeerConnection(
+      PeerConnectionMock as unknown as typeof RTCPeerConnection,
+      ladder,
+    )
+    return new SimulcastPeerConnection() as unknown as PeerConnectionMock
+  }
+
+  describe('createSimulcastPeerConnection', () => {
+    it('adds video tracks with an encoding for each layer', () => {
+      const pc = createPeerConnection()
+      const track = { kind: 'video' } as MediaStreamTrack
+      const stream = {} as MediaStream
+      const sender = (pc as unknown as RTCPeerConnection)
+      .addTrack(track, stream)
+      expect(sender).toBe('video-sender')
+      expect(pc.addTrackCalls).toEqual([])
+      expect(pc.addTransceiverCalls).toEqual([[ track, {
+        direction: 'sendrecv',
+        streams: [ stream ],
+        sendEncodings: ladder,
+      }]])
+    })
+
+    it('adds audio tracks as is', () => {
+      const pc = createPeerConnection()
+      const track = { kind: 'audio' } as MediaStreamTrack
+      const stream = {} as MediaStream
+      const sender = (pc as unknown as RTCPeerConnection)
+      .addTrack(track, stream)
+      expect(sender).toBe('audio-sender')
+      expect(pc.addTrackCalls).toEqual([[ track, stream ]])
+      expect(pc.addTransceiverCalls).toEqual([])
+    })
+  })
+
+  describe('getWebRTC', () => {
+    it('returns undefined without simulcast layers', () => {
+      expect(getWebRTC()).toBe(undefined)
+      expect(getWebRTC([])).toBe(undefined)
+    })
+  })
+
+})
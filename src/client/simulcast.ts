@@ -0,0 +1,50 @@
+import { SimulcastLayer } from './window'
+
+export interface WebRTC {
+  RTCPeerConnection: typeof RTCPeerConnection
+  RTCSessionDescription: typeof RTCSessionDescription
+  RTCIceCandidate: typeof RTCIceCandidate
+}
+
+// createSimulcastPeerConnection extends RTCPeerConnection so that the video
+// tracks added by simple-peer are sent with one encoding for each layer of
+// the simulcast ladder.
+export function createSimulcastPeerConnection(
+  Base: typeof RTCPeerConnection,
+  ladder: SimulcastLayer[],
+): typeof RTCPeerConnection {
+  return class SimulcastPeerConnection extends Base {
+    addTrack(
+      track: MediaStreamTrack,
+      ...streams: MediaStream[]
+    ): RTCRtpSender {
+      if (track.kind !== 'video') {
+        return super.addTrack(track, ...streams)
+      }
+
+      return this.addTransceiver(track, {
+        direction: 'sendrecv',
+        streams,
+        sendEncodings: ladder.map(layer => ({
+          rid: layer.rid,
+          scaleResolutionDownBy: layer.scaleResolutionDownBy,
+          maxBitrate: layer.maxBitrate,
+        })),
+      }).sender
+    }
+  }
+}
+
+// getWebRTC returns the implementation for the wrtc option of simple-peer,
+// or undefined when the browser implementation should be used as is.
+export function getWebRTC(ladder: SimulcastLayer[] = []): WebRTC | undefined {
+  if (!ladder.length) {
+    return undefined
+  }
+
+  return {
+    RTCPeerConnection: createSimulcastPeerConnection(RTCPeerConnection, ladder),
+    RTCSessionDescription,
+    RTCIceCandidate,
+  }
+}
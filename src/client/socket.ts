@@ -3,7 +3,17 @@ import { config } from './window'
 import { SocketClient, TypedEmitter } from './ws'
 export type ClientSocket = TypedEmitter<SocketEvent>
 
-const wsUrl = location.origin.replace(/^http/, 'ws') +
-  config.baseUrl + '/ws/' + config.callId + '/' + config.peerId
+export function getWebSocketUrl(baseUrl: string, resumeToken = ''): string {
+  const wsUrl = baseUrl.replace(/^http/, 'ws') +
+    '/ws/' + config.callId + '/' + config.peerId
 
-export default new SocketClient<SocketEvent>(wsUrl)
+  if (!resumeToken) {
+    return wsUrl
+  }
+
+  return wsUrl + '?resumeToken=' + encodeURIComponent(resumeToken)
+}
+
+export default new SocketClient<SocketEvent>(
+  getWebSocketUrl(location.origin + config.baseUrl),
+)
@@ -19,6 +19,15 @@ export interface ClientConfig {
 export interface PeerConfig {
   iceServers: RTCIceServer[]
   encodedInsertableStreams: boolean
+  // simulcast contains the encodings the SFU expects video publishers to
+  // send, ordered from the lowest to the highest bitrate.
+  simulcast?: SimulcastLayer[]
+}
+
+export interface SimulcastLayer {
+  rid: string
+  scaleResolutionDownBy: number
+  maxBitrate: number
 }
 
 export const config: ClientConfig  = JSON.parse(valueOf('config')!)
@@ -22,11 +22,18 @@ export class SocketClient<E extends Events> extends SimpleEmitter<E> {
   pingIntervalTimeout = 5000
   protected pingInterval: NodeJS.Timeout | undefined
 
-  constructor(readonly url: string) {
+  constructor(protected url: string) {
     super()
     this.connect()
   }
 
+  // reconnect closes the current connection and connects to a different url.
+  reconnect(url: string) {
+    debug('reconnecting to: %s', url)
+    this.url = url
+    this.ws.close()
+  }
+
   protected connect() {
     debug('connecting to: %s', this.url)
     const ws = this.ws = new WebSocket(this.url)